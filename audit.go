@@ -0,0 +1,200 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// An AuditRecord describes a single license decision. Result is StatusSuccess
+// if the decision allowed access. Soft is true if the decision failed with
+// Result, but success was returned because the product is in soft enforcement
+// mode. Generation is the number of the claims
+// snapshot of the Kustomer instance, starting at 1 for the first fetched
+// claims and 0 for claims which did not come from a Kustomer instance.
+type AuditRecord struct {
+	Time       time.Time  `json:"time"`
+	Operation  string     `json:"operation"`
+	Product    string     `json:"product,omitempty"`
+	Claim      string     `json:"claim,omitempty"`
+	Expression string     `json:"expression,omitempty"`
+	Result     ErrNumeric `json:"result"`
+	Message    string     `json:"message,omitempty"`
+	Soft       bool       `json:"soft,omitempty"`
+	Generation uint64     `json:"generation"`
+	Trusted    bool       `json:"trusted"`
+	Online     bool       `json:"online"`
+}
+
+// An AuditHook is called with every license decision. Hooks are called
+// synchronously, so they should return quickly.
+type AuditHook func(record *AuditRecord)
+
+// WithAuditHook returns a view of the associated claims which calls the
+// provided hook with every decision of the Ensure* and Get* functions. Pass
+// nil to disable auditing. The associated claims are not modified.
+func (kpc *KopanoProductClaims) WithAuditHook(hook AuditHook) *KopanoProductClaims {
+	derived := *kpc
+	derived.auditHook = hook
+	return &derived
+}
+
+// audit records the decision of the provided operation. It is meant to be
+// deferred with a pointer to the named error result of the operation.
+func (kpc *KopanoProductClaims) audit(operation, product, claim string, errp *error) {
+	if kpc.auditHook == nil {
+		return
+	}
+	kpc.record(&AuditRecord{
+		Operation: operation,
+		Product:   product,
+		Claim:     claim,
+	}, *errp)
+}
+
+// auditExpr records the decision of the provided policy expression.
+func (kpc *KopanoProductClaims) auditExpr(expression string, errp *error) {
+	if kpc.auditHook == nil {
+		return
+	}
+	kpc.record(&AuditRecord{
+		Operation:  "EnsureExpr",
+		Expression: expression,
+	}, *errp)
+}
+
+func (kpc *KopanoProductClaims) record(record *AuditRecord, err error) {
+	record.Time = kpc.now()
+	record.Result = StatusSuccess
+	record.Generation = kpc.generation
+	record.Trusted = kpc.response.Trusted
+	record.Online = !kpc.response.Offline
+	if err != nil {
+		record.Result = asErrNumeric(err)
+		record.Message = err.Error()
+	}
+	kpc.auditHook(record)
+}
+
+// quiet returns a view of the associated claims without audit hook and soft
+// enforcement. Audited functions use it for their internal lookups, so that
+// every decision is recorded and enforced exactly once.
+func (kpc *KopanoProductClaims) quiet() *KopanoProductClaims {
+	if kpc.auditHook == nil && kpc.softEnforcement == nil {
+		return kpc
+	}
+	derived := *kpc
+	derived.auditHook = nil
+	derived.softEnforcement = nil
+	return &derived
+}
+
+// An AuditFileSink writes audit records as JSON lines to a file. When the file
+// grows beyond its maximum size, it is rotated to path.1, path.1 to path.2 and
+// so on, keeping at most the configured number of rotated files.
+type AuditFileSink struct {
+	mutex sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+}
+
+// NewAuditFileSink opens the file at the provided path for appending audit
+// records. A maxSize of zero or less disables rotation.
+func NewAuditFileSink(path string, maxSize int64, maxBackups int) (*AuditFileSink, error) {
+	s := &AuditFileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *AuditFileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *AuditFileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.maxBackups > 0 {
+		for i := s.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1)) //nolint:errcheck
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// Write writes the provided record as JSON line and rotates the file if
+// needed.
+func (s *AuditFileSink) Write(record *AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Audit is an AuditHook which writes the provided record, ignoring errors.
+func (s *AuditFileSink) Audit(record *AuditRecord) {
+	s.Write(record) //nolint:errcheck
+}
+
+// Close closes the file of the associated sink.
+func (s *AuditFileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
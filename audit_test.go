@@ -0,0 +1,109 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditHook(t *testing.T) {
+	var records []*AuditRecord
+	kpc := newTestKopanoProductClaims().WithAuditHook(func(record *AuditRecord) {
+		records = append(records, record)
+	})
+	kpc.generation = 3
+
+	if err := kpc.EnsureInt64WithOperator("groupware", "max_users", 50, OperatorGreaterThan); err != nil {
+		t.Fatal(err)
+	}
+	if err := kpc.EnsureString("groupware", "edition", "basic"); err == nil {
+		t.Errorf("expected edition mismatch")
+	}
+	if err := kpc.EnsureExpr(`groupware.ok && trusted`); err != nil {
+		t.Fatal(err)
+	}
+	if err := kpc.EnsureOK("meet"); err == nil {
+		t.Errorf("expected meet to be not licensed")
+	}
+
+	expected := []struct {
+		operation string
+		claim     string
+		result    ErrNumeric
+	}{
+		{"EnsureInt64WithOperator", "max_users", StatusSuccess},
+		{"EnsureString", "edition", ErrEnsureProductClaimValueMismatch},
+		{"EnsureExpr", "", StatusSuccess},
+		{"EnsureOK", "", ErrEnsureProductNotLicensed},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r.Operation != e.operation || r.Claim != e.claim || r.Result != e.result {
+			t.Errorf("record %d: got %s %s %v, expected %s %s %v", i, r.Operation, r.Claim, r.Result, e.operation, e.claim, e.result)
+		}
+		if r.Generation != 3 || !r.Trusted || !r.Online || r.Time.IsZero() {
+			t.Errorf("record %d: unexpected state %+v", i, r)
+		}
+	}
+	if records[2].Expression != `groupware.ok && trusted` {
+		t.Errorf("unexpected expression: %s", records[2].Expression)
+	}
+
+	records = nil
+	if _, err := newTestKopanoProductClaims().GetString("groupware", "edition"); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no records without hook")
+	}
+}
+
+func TestAuditFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewAuditFileSink(path, 300, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kpc := newTestKopanoProductClaims().WithAuditHook(sink.Audit)
+	for i := 0; i < 10; i++ {
+		kpc.GetInt64("groupware", "max_users") //nolint:errcheck
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		f, openErr := os.Open(name)
+		if openErr != nil {
+			t.Fatal(openErr)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var record AuditRecord
+			if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("%s: invalid record: %v", name, err)
+			}
+			if record.Operation != "GetInt64" || record.Claim != "max_users" {
+				t.Errorf("%s: unexpected record: %+v", name, record)
+			}
+		}
+		f.Close()
+		if info, statErr := os.Stat(name); statErr != nil || info.Size() > 300 {
+			t.Errorf("%s: expected rotation at 300 bytes", name)
+		}
+	}
+	if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups")
+	}
+}
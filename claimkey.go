@@ -0,0 +1,270 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// ClaimValue is the set of Go types product claim values can be read as. Time
+// values are read from RFC 3339 strings or unix seconds and duration values
+// are read from Go duration strings (like 720h) or seconds. Object claims are
+// read as map[string]interface{} or, if all values are strings, as
+// map[string]string.
+type ClaimValue interface {
+	bool | string | int64 | float64 | time.Time | time.Duration |
+		[]string | []int64 | []float64 |
+		map[string]interface{} | map[string]string
+}
+
+// Get returns the provided product claim value as T. If the product or the
+// claim is not found, the returned error describes the reason why the claim
+// value is not available. If the claim value is not of type T,
+// ErrEnsureProductClaimValueTypeMismatch is returned. Integer claim values
+// which cannot be represented exactly as int64 fail with
+// ErrEnsureProductClaimValueNotInteger. Like with all getters and ensure
+// functions, the claim can be a JSON Pointer path (for example
+// /limits/users/max) to select a value nested inside a claim.
+func Get[T ClaimValue](kpc *KopanoProductClaims, product, claim string) (T, error) {
+	return auditedGet[T](kpc, "Get", product, claim)
+}
+
+// auditedGet is like Get, but records the decision with the provided
+// operation name, so the typed getters are audited under their own names.
+func auditedGet[T ClaimValue](kpc *KopanoProductClaims, operation, product, claim string) (result T, err error) {
+	defer kpc.audit(operation, product, claim, &err)
+	return getClaim[T](kpc, product, claim)
+}
+
+func getClaim[T ClaimValue](kpc *KopanoProductClaims, product, claim string) (T, error) {
+	var result T
+
+	v, err := kpc.ensureValue(product, claim)
+	if err != nil {
+		return result, err
+	}
+
+	if code := convertClaimValue(v, &result); code != StatusSuccess {
+		return result, newEnsureValueError(code, product, claim, "", claimValueTypeName[T](), v)
+	}
+	return result, nil
+}
+
+// Ensure returns an error if the provided product or the claim value is not
+// found. Furthermore the claim value is compared to the provided value and if
+// it is not a match, an error is returned as well. For []string, the claim
+// value matches if it contains all of the provided values. All other arrays
+// and objects must be deeply equal.
+func Ensure[T ClaimValue](kpc *KopanoProductClaims, product, claim string, value T) error {
+	return ensure(kpc, "Ensure", product, claim, value)
+}
+
+// ensure is like Ensure, but records the decision with the provided operation
+// name, so the typed ensure functions are audited under their own names.
+func ensure[T ClaimValue](kpc *KopanoProductClaims, operation, product, claim string, value T) (err error) {
+	defer kpc.ensured(operation, product, claim, &err)
+	tv, err := getClaim[T](kpc, product, claim)
+	if err != nil {
+		return err
+	}
+
+	switch expected := any(value).(type) {
+	case time.Time:
+		if !expected.Equal(any(tv).(time.Time)) {
+			return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, tv)
+		}
+	case []string:
+		actual := any(tv).([]string)
+		for _, v := range expected {
+			found := false
+			for _, e := range actual {
+				if v == e {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", v, actual)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(value, tv) {
+			return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, tv)
+		}
+	}
+	return nil
+}
+
+// convertClaimValue stores the provided claim value as T in result. It returns
+// StatusSuccess, ErrEnsureProductClaimValueTypeMismatch or, for integers which
+// cannot be represented exactly, ErrEnsureProductClaimValueNotInteger.
+func convertClaimValue[T ClaimValue](v interface{}, result *T) ErrNumeric { //nolint:gocyclo
+	ok := false
+	switch p := any(result).(type) {
+	case *bool:
+		*p, ok = v.(bool)
+	case *string:
+		*p, ok = v.(string)
+	case *int64:
+		if _, isNumber := claimNumber(v); !isNumber {
+			break
+		}
+		var exact bool
+		if *p, exact = claimInt64(v); !exact {
+			return ErrEnsureProductClaimValueNotInteger
+		}
+		ok = true
+	case *float64:
+		*p, ok = claimNumber(v)
+	case *time.Time:
+		if tv, isString := v.(string); isString {
+			t, err := time.Parse(time.RFC3339, tv)
+			*p, ok = t, err == nil
+		} else if f, isNumber := claimNumber(v); isNumber {
+			seconds, fraction := math.Modf(f)
+			*p, ok = time.Unix(int64(seconds), int64(fraction*1e9)), true
+		}
+	case *time.Duration:
+		if tv, isString := v.(string); isString {
+			d, err := time.ParseDuration(tv)
+			*p, ok = d, err == nil
+		} else if f, isNumber := claimNumber(v); isNumber {
+			*p, ok = time.Duration(f*float64(time.Second)), true
+		}
+	case *[]string:
+		return convertClaimArray(v, p)
+	case *[]int64:
+		return convertClaimArray(v, p)
+	case *[]float64:
+		return convertClaimArray(v, p)
+	case *map[string]interface{}:
+		var tvm map[string]interface{}
+		if tvm, ok = v.(map[string]interface{}); ok {
+			// Copy, so callers cannot modify the shared claims.
+			*p = copyClaimValue(tvm).(map[string]interface{})
+		}
+	case *map[string]string:
+		var tvm map[string]interface{}
+		if tvm, ok = v.(map[string]interface{}); ok {
+			m := make(map[string]string, len(tvm))
+			for k, mv := range tvm {
+				if m[k], ok = mv.(string); !ok {
+					return ErrEnsureProductClaimValueTypeMismatch
+				}
+			}
+			*p = m
+		}
+	}
+	if !ok {
+		return ErrEnsureProductClaimValueTypeMismatch
+	}
+	return StatusSuccess
+}
+
+// copyClaimValue returns a deep copy of the provided decoded JSON value.
+func copyClaimValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for k, mv := range tv {
+			m[k] = copyClaimValue(mv)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(tv))
+		for i, iv := range tv {
+			a[i] = copyClaimValue(iv)
+		}
+		return a
+	}
+	return v
+}
+
+func convertClaimArray[T ClaimValue](v interface{}, result *[]T) ErrNumeric {
+	tvi, ok := v.([]interface{})
+	if !ok {
+		return ErrEnsureProductClaimValueTypeMismatch
+	}
+	values := make([]T, len(tvi))
+	for i, iv := range tvi {
+		if code := convertClaimValue(iv, &values[i]); code != StatusSuccess {
+			return code
+		}
+	}
+	*result = values
+	return StatusSuccess
+}
+
+// claimNumber returns the provided claim value as float64. Claim numbers are
+// either json.Number, when decoded from an API response, or float64.
+func claimNumber(v interface{}) (float64, bool) {
+	switch tv := v.(type) {
+	case float64:
+		return tv, true
+	case json.Number:
+		f, err := tv.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// claimInt64 returns the provided claim number as int64. The second return
+// value is false if the number is not integral or out of the int64 range.
+func claimInt64(v interface{}) (int64, bool) {
+	if n, isNumber := v.(json.Number); isNumber {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+	}
+	f, ok := claimNumber(v)
+	// 2^63 is exactly representable, so the upper bound must be exclusive.
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func claimValueTypeName[T ClaimValue]() string {
+	var zero T
+	return fmt.Sprintf("%T", zero)
+}
+
+// A ClaimKey is a typed declaration of a product claim. Declare claim keys
+// once and use them instead of passing product and claim names around, so
+// misspelled names and wrong types are caught in a single place.
+type ClaimKey[T ClaimValue] struct {
+	Product string
+	Claim   string
+}
+
+// NewClaimKey returns a new ClaimKey for the provided product and claim.
+func NewClaimKey[T ClaimValue](product, claim string) ClaimKey[T] {
+	return ClaimKey[T]{
+		Product: product,
+		Claim:   claim,
+	}
+}
+
+// Get returns the value of the associated claim key from the provided claims.
+// See the Get function for details.
+func (key ClaimKey[T]) Get(kpc *KopanoProductClaims) (T, error) {
+	return Get[T](kpc, key.Product, key.Claim)
+}
+
+// Ensure compares the value of the associated claim key from the provided
+// claims with the provided value. See the Ensure function for details.
+func (key ClaimKey[T]) Ensure(kpc *KopanoProductClaims, value T) error {
+	return Ensure(kpc, key.Product, key.Claim, value)
+}
+
+// String returns the product and claim name of the associated claim key.
+func (key ClaimKey[T]) String() string {
+	return key.Product + "." + key.Claim
+}
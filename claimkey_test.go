@@ -0,0 +1,38 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClaimKey(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+
+	maxUsers := NewClaimKey[int64]("groupware", "max_users")
+	if v, err := maxUsers.Get(kpc); err != nil || v != 100 {
+		t.Errorf("unexpected value: %v (%v)", v, err)
+	}
+	if err := maxUsers.Ensure(kpc, 100); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	features := NewClaimKey[[]string]("groupware", "features")
+	if err := features.Ensure(kpc, []string{"webapp", "archiver"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := features.Ensure(kpc, []string{"kdav"}); !errors.Is(err, ErrEnsureProductClaimValueMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := Get[bool](kpc, "groupware", "edition"); !errors.Is(err, ErrEnsureProductClaimValueTypeMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Get[string](kpc, "groupware", "editon"); !errors.Is(err, ErrEnsureProductClaimNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
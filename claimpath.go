@@ -0,0 +1,52 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"strconv"
+	"strings"
+)
+
+// isClaimPath returns true if the provided claim is a JSON Pointer (RFC 6901)
+// path into a nested claim value, like /limits/users/max.
+func isClaimPath(claim string) bool {
+	return strings.HasPrefix(claim, "/")
+}
+
+// splitClaimPath returns the unescaped reference tokens of the provided JSON
+// Pointer claim path.
+func splitClaimPath(claim string) []string {
+	tokens := strings.Split(claim[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// resolveClaimPath returns the value referenced by the provided JSON Pointer
+// claim path in the provided claims. The first reference token selects the
+// claim, all others select object members or array elements of the value.
+func resolveClaimPath(claims map[string]interface{}, product, claim string) (interface{}, error) {
+	var v interface{} = claims
+	for _, token := range splitClaimPath(claim) {
+		switch tv := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = tv[token]; !ok {
+				return nil, newEnsureError(ErrEnsureProductClaimNotFound, product, claim)
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(tv) || strings.HasPrefix(token, "+") {
+				return nil, newEnsureError(ErrEnsureProductClaimNotFound, product, claim)
+			}
+			v = tv[index]
+		default:
+			return nil, newEnsureValueError(ErrEnsureProductClaimValueTypeMismatch, product, claim, "", "object or array", v)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClaimPath(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Products["groupware"].Claims["limits"] = map[string]interface{}{
+		"users":     map[string]interface{}{"max": float64(250)},
+		"tiers":     []interface{}{"basic", "pro"},
+		"a/b~c":     true,
+		"unlimited": false,
+	}
+
+	if v, err := kpc.GetInt64("groupware", "/limits/users/max"); err != nil || v != 250 {
+		t.Errorf("unexpected value: %v (%v)", v, err)
+	}
+	if err := kpc.EnsureString("groupware", "/limits/tiers/1", "pro"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := kpc.EnsureBool("groupware", "/limits/a~1b~0c", true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := kpc.EnsureInt64WithOperator("groupware", "/max_users", 50, OperatorGreaterThan); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for path, expected := range map[string]error{
+		"/limits/users/min":     ErrEnsureProductClaimNotFound,
+		"/limits/tiers/2":       ErrEnsureProductClaimNotFound,
+		"/limits/tiers/-1":      ErrEnsureProductClaimNotFound,
+		"/limits/unlimited/max": ErrEnsureProductClaimValueTypeMismatch,
+		"/limits/users":         ErrEnsureProductClaimValueTypeMismatch,
+	} {
+		if _, err := kpc.GetInt64("groupware", path); !errors.Is(err, expected) {
+			t.Errorf("%s: unexpected error: %v", path, err)
+		}
+	}
+	p, err := kpc.Product("groupware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]ClaimKind{
+		"/limits/users/max": ClaimKindNumber,
+		"/limits/tiers":     ClaimKindArray,
+		"/limits/a~1b~0c":   ClaimKindBool,
+		"/limits/users/min": ClaimKindNone,
+	} {
+		if kind := p.ClaimKind(path); kind != expected {
+			t.Errorf("%s: expected kind %s, got %s", path, expected, kind)
+		}
+	}
+
+	for expression, expected := range map[string]error{
+		`groupware./limits/users/max >= 250`: nil,
+		`"pro" == groupware./limits/tiers/1`: nil,
+		`groupware./limits/a~1b~0c`:          nil,
+		`!groupware./limits/unlimited`:       nil,
+		`groupware./limits/users/min > 1`:    ErrEnsureProductClaimNotFound,
+		`groupware./limits/a b`:              ErrEnsureInvalidExpression,
+	} {
+		if err := kpc.EnsureExpr(expression); !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v, got %v", expression, expected, err)
+		}
+	}
+}
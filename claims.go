@@ -0,0 +1,117 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A License is a single license of a Claims set. The registered claims are
+// available as fields, all claims including the registered ones are available
+// with Claim.
+type License struct {
+	ID       string
+	Subject  string
+	Issuer   string
+	IssuedAt time.Time
+	Expiry   time.Time
+
+	claims map[string]interface{}
+}
+
+// Claim returns the value of the claim with the provided name of the
+// associated license. The second return value is false if the license does
+// not have that claim.
+func (l *License) Claim(name string) (interface{}, bool) {
+	v, ok := l.claims[name]
+	return v, ok
+}
+
+// ClaimNames returns the names of all claims of the associated license.
+func (l *License) ClaimNames() []string {
+	names := make([]string, 0, len(l.claims))
+	for name := range l.claims {
+		names = append(names, name)
+	}
+	return names
+}
+
+// MarshalJSON implements the json.Marshaler interface. Only the registered
+// claims are included.
+func (l *License) MarshalJSON() ([]byte, error) {
+	s := &struct {
+		ID       string `json:"jti,omitempty"`
+		Subject  string `json:"sub,omitempty"`
+		Issuer   string `json:"iss,omitempty"`
+		IssuedAt int64  `json:"iat,omitempty"`
+		Expiry   int64  `json:"exp,omitempty"`
+	}{
+		ID:      l.ID,
+		Subject: l.Subject,
+		Issuer:  l.Issuer,
+	}
+	if !l.IssuedAt.IsZero() {
+		s.IssuedAt = l.IssuedAt.Unix()
+	}
+	if !l.Expiry.IsZero() {
+		s.Expiry = l.Expiry.Unix()
+	}
+	return json.Marshal(s)
+}
+
+func newLicense(claims map[string]interface{}) *License {
+	l := &License{
+		claims: claims,
+	}
+	l.ID, _ = claims["jti"].(string)
+	l.Subject, _ = claims["sub"].(string)
+	l.Issuer, _ = claims["iss"].(string)
+	_ = convertClaimValue(claims["iat"], &l.IssuedAt)
+	_ = convertClaimValue(claims["exp"], &l.Expiry)
+	return l
+}
+
+// Licenses returns the licenses of the associated Claims set.
+func (c *Claims) Licenses() ([]*License, error) {
+	// Round trip through JSON, to only depend on the wire format of the API.
+	b, err := json.Marshal(c.response)
+	if err != nil {
+		return nil, fmt.Errorf("claims parse error: %w", err)
+	}
+
+	var raw []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err = decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("claims parse error: %w", err)
+	}
+
+	licenses := make([]*License, 0, len(raw))
+	for _, claims := range raw {
+		if claims != nil {
+			licenses = append(licenses, newLicense(claims))
+		}
+	}
+	return licenses, nil
+}
+
+// License returns the license with the provided ID of the associated Claims
+// set. ErrStatusLicenseNotFound is returned if there is no such license.
+func (c *Claims) License(id string) (*License, error) {
+	licenses, err := c.Licenses()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range licenses {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return nil, ErrStatusLicenseNotFound
+}
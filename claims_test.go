@@ -0,0 +1,48 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestClaimsLicenses(t *testing.T) {
+	c := &Claims{}
+	if err := json.Unmarshal([]byte(`[
+		{"jti": "lic-1", "sub": "customer", "iss": "kopano", "iat": 1609459200, "exp": 1640995200, "kopano.com": {"groupware": {"max_users": 100}}},
+		{"jti": "lic-2", "sub": "customer"}
+	]`), &c.response); err != nil {
+		t.Fatal(err)
+	}
+
+	licenses, err := c.Licenses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(licenses) != 2 || licenses[0].ID != "lic-1" || licenses[1].Subject != "customer" {
+		t.Fatalf("unexpected licenses: %v", licenses)
+	}
+
+	l, err := c.License("lic-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Issuer != "kopano" || l.IssuedAt.Unix() != 1609459200 || l.Expiry.Unix() != 1640995200 {
+		t.Errorf("unexpected license: %+v", l)
+	}
+	if _, ok := l.Claim("kopano.com"); !ok {
+		t.Errorf("expected kopano.com claim")
+	}
+	if b, _ := json.Marshal(l); string(b) != `{"jti":"lic-1","sub":"customer","iss":"kopano","iat":1609459200,"exp":1640995200}` {
+		t.Errorf("unexpected license JSON: %s", b)
+	}
+
+	if _, err = c.License("lic-3"); !errors.Is(err, ErrStatusLicenseNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
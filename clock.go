@@ -0,0 +1,229 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Clock provides the current time and timers. All time dependent behavior of
+// this module, like retry delays, fetch timeouts and expiry checks, uses the
+// Clock of its Config.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer is a single event timer created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is the Clock used if no other clock is explicitly specified. It
+// uses the wall clock of the time package.
+var SystemClock Clock = &systemClock{}
+
+type systemClock struct{}
+
+func (c *systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c *systemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t *systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// WithClockTimeout is like context.WithTimeout, but uses the provided clock.
+func WithClockTimeout(ctx context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if clock == SystemClock {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	c := &clockTimeoutContext{
+		Context:  cancelCtx,
+		deadline: clock.Now().Add(timeout),
+	}
+	timer := clock.NewTimer(timeout)
+	go func() {
+		select {
+		case <-timer.C():
+			c.mutex.Lock()
+			c.timedOut = true
+			c.mutex.Unlock()
+			cancel()
+		case <-cancelCtx.Done():
+			timer.Stop()
+		}
+	}()
+	return c, cancel
+}
+
+// clockTimeoutContext reports context.DeadlineExceeded if it was cancelled by
+// the timer of WithClockTimeout.
+type clockTimeoutContext struct {
+	context.Context
+
+	mutex    sync.Mutex
+	deadline time.Time
+	timedOut bool
+}
+
+func (c *clockTimeoutContext) Deadline() (time.Time, bool) {
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(c.deadline) {
+		return parent, true
+	}
+	return c.deadline, true
+}
+
+func (c *clockTimeoutContext) Err() error {
+	err := c.Context.Err()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil && c.timedOut {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// A FakeClock is a Clock which only advances when told to. Use it in tests to
+// simulate time passing without waiting, for example days until a license
+// expires.
+type FakeClock struct {
+	mutex sync.Mutex
+
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a new FakeClock set to the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+// Now returns the current time of the associated clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After returns a channel which receives the time of the associated clock once
+// it has been advanced by at least the provided duration.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer creates a new Timer which fires once the associated clock has been
+// advanced by at least the provided duration.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{
+		clock: c,
+		at:    c.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the associated clock forward by the provided duration and
+// fires all timers which are due, in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	now := c.now.Add(d)
+	c.mutex.Unlock()
+
+	c.Set(now)
+}
+
+// Set sets the associated clock to the provided time and fires all timers
+// which are due, in order. The clock never moves backwards.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Before(c.now) {
+		return
+	}
+	c.now = now
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- now
+	}
+	c.timers = pending
+}
+
+// Waiters returns the number of timers of the associated clock which have not
+// fired yet and which were not stopped. Tests can use it to wait until the
+// code under test is waiting for the clock.
+func (c *FakeClock) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	day := clock.After(24 * time.Hour)
+	hour := clock.NewTimer(time.Hour)
+	stopped := clock.NewTimer(time.Minute)
+	if !stopped.Stop() {
+		t.Errorf("expected pending timer to stop")
+	}
+	if clock.Waiters() != 2 {
+		t.Errorf("expected 2 waiters, got %d", clock.Waiters())
+	}
+
+	clock.Advance(2 * time.Hour)
+	select {
+	case now := <-hour.C():
+		if !now.Equal(start.Add(2 * time.Hour)) {
+			t.Errorf("unexpected timer time: %v", now)
+		}
+	default:
+		t.Errorf("expected hour timer to fire")
+	}
+	select {
+	case <-day:
+		t.Errorf("expected day timer to be pending")
+	default:
+	}
+
+	clock.Set(start.Add(48 * time.Hour))
+	<-day
+	if clock.Waiters() != 0 {
+		t.Errorf("expected no waiters, got %d", clock.Waiters())
+	}
+	if !clock.Now().Equal(start.Add(48 * time.Hour)) {
+		t.Errorf("unexpected now: %v", clock.Now())
+	}
+}
+
+func TestWithClockTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	ctx, cancel := WithClockTimeout(context.Background(), clock, time.Minute)
+	defer cancel()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected context to be active, got %v", ctx.Err())
+	}
+	clock.Advance(time.Minute)
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", ctx.Err())
+	}
+
+	ctx, cancel = WithClockTimeout(context.Background(), clock, time.Minute)
+	cancel()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("expected canceled, got %v", ctx.Err())
+	}
+}
+
+func TestEnsureNotExpiredWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	kpc := newTestKopanoProductClaims()
+	kpc.clock = clock
+	kpc.response.Products["groupware"].Claims["exp"] = "2021-06-30T00:00:00Z"
+
+	if err := kpc.EnsureNotExpired("groupware", "exp", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * 24 * time.Hour)
+	if err := kpc.EnsureNotExpired("groupware", "exp", time.Time{}); !errors.Is(err, ErrEnsureProductClaimExpired) {
+		t.Errorf("expected expired after 30 days, got %v", err)
+	}
+}
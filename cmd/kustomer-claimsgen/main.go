@@ -0,0 +1,264 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+
+type claim struct {
+	Product string
+	Claim   string
+
+	GoName string
+	GoType string
+	CName  string
+
+	// Names of the kustomer_ensure_* exports and the C value type used by the
+	// generated C helpers. Empty if not available for the claim kind.
+	CGet        string
+	CGetType    string
+	CEnsure     string
+	CEnsureType string
+}
+
+func newClaim(product string, cs *kustomer.ClaimSchema) (*claim, error) {
+	if goNamePart(product) == "" || goNamePart(cs.Name) == "" {
+		return nil, fmt.Errorf("claim %s.%s has no valid identifier", product, cs.Name)
+	}
+	c := &claim{
+		Product: product,
+		Claim:   cs.Name,
+		GoName:  goName(product + "_" + cs.Name),
+		CName:   cName(product) + "_" + cName(cs.Name),
+	}
+
+	switch cs.Kind {
+	case kustomer.ClaimKindBool:
+		c.GoType = "bool"
+		c.CGet, c.CGetType = "kustomer_ensure_get_bool", "int"
+		c.CEnsure, c.CEnsureType = "kustomer_ensure_ensure_bool", "int"
+	case kustomer.ClaimKindString:
+		c.GoType = "string"
+		c.CGet, c.CGetType = "kustomer_ensure_get_string", "char*"
+		c.CEnsure, c.CEnsureType = "kustomer_ensure_ensure_string", "char*"
+	case kustomer.ClaimKindNumber:
+		if cs.Integer {
+			c.GoType = "int64"
+			c.CGet, c.CGetType = "kustomer_ensure_get_int64", "long long"
+			c.CEnsure, c.CEnsureType = "kustomer_ensure_ensure_int64", "long long"
+		} else {
+			c.GoType = "float64"
+			c.CGet, c.CGetType = "kustomer_ensure_get_float64", "double"
+			c.CEnsure, c.CEnsureType = "kustomer_ensure_ensure_float64", "double"
+		}
+	case kustomer.ClaimKindArray:
+		switch {
+		case cs.Items == kustomer.ClaimKindNumber && cs.Integer:
+			c.GoType = "[]int64"
+			c.CGet, c.CGetType = "kustomer_ensure_get_int64Array_json", "char*"
+		case cs.Items == kustomer.ClaimKindNumber:
+			c.GoType = "[]float64"
+			c.CGet, c.CGetType = "kustomer_ensure_get_float64Array_json", "char*"
+		default:
+			c.GoType = "[]string"
+			c.CGet, c.CGetType = "kustomer_ensure_get_stringArray_json", "char*"
+			c.CEnsure, c.CEnsureType = "kustomer_ensure_ensure_stringArray_value", "char*"
+		}
+	case kustomer.ClaimKindObject:
+		c.GoType = "map[string]interface{}"
+		c.CGet, c.CGetType = "kustomer_ensure_get_object_json", "char*"
+	default:
+		return nil, fmt.Errorf("claim %s.%s of kind %s is not supported", product, cs.Name, cs.Kind)
+	}
+	return c, nil
+}
+
+// goName returns the provided name as exported Go identifier, for example
+// max_users becomes MaxUsers. Names which do not start with an upper case
+// letter after conversion, like 2fa, get an X prefix.
+func goName(name string) string {
+	s := goNamePart(name)
+	if s != "" && !token.IsExported(s) {
+		return "X" + s
+	}
+	return s
+}
+
+func goNamePart(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	return b.String()
+}
+
+// cName returns the provided name as lower case C identifier.
+func cName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by kustomer-claimsgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+{{range .Claims}}
+// {{.GoName}} returns the {{.Claim}} claim value of the {{.Product}} product.
+func {{.GoName}}(kpc *kustomer.KopanoProductClaims) ({{.GoType}}, error) {
+	return kustomer.Get[{{.GoType}}](kpc, {{printf "%q" .Product}}, {{printf "%q" .Claim}})
+}
+
+// Ensure{{.GoName}} ensures the {{.Claim}} claim value of the {{.Product}}
+// product. See kustomer.Ensure for details.
+func Ensure{{.GoName}}(kpc *kustomer.KopanoProductClaims, value {{.GoType}}) error {
+	return kustomer.Ensure(kpc, {{printf "%q" .Product}}, {{printf "%q" .Claim}}, value)
+}
+{{end}}`))
+
+var cTemplate = template.Must(template.New("c").Parse(`// Code generated by kustomer-claimsgen. DO NOT EDIT.
+
+// Include after kustomer.h.
+
+#ifndef {{.Guard}}
+#define {{.Guard}}
+{{range .Claims}}{{if .CGet}}
+static inline unsigned long long kustomer_claim_get_{{.CName}}(void *transaction, {{.CGetType}} *value)
+{
+	struct {{.CGet}}_return r = {{.CGet}}(transaction, (char*){{printf "%q" .Product}}, (char*){{printf "%q" .Claim}});
+	if (value != NULL) {
+		*value = r.r1;
+	}
+	return r.r0;
+}
+{{end}}{{if .CEnsure}}
+static inline unsigned long long kustomer_claim_ensure_{{.CName}}(void *transaction, {{.CEnsureType}} value)
+{
+	return {{.CEnsure}}(transaction, (char*){{printf "%q" .Product}}, (char*){{printf "%q" .Claim}}, value);
+}
+{{end}}{{end}}
+#endif /* {{.Guard}} */
+`))
+
+func main() {
+	schemaFile := flag.String("schema", "", "Path to the claims schema file (JSON or YAML)")
+	packageName := flag.String("package", "claims", "Package name of the generated Go file")
+	goOut := flag.String("go", "", "Path of the generated Go file")
+	cOut := flag.String("c", "", "Path of the generated C header file")
+	flag.Parse()
+
+	if *schemaFile == "" || (*goOut == "" && *cOut == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*schemaFile, *packageName, *goOut, *cOut); err != nil {
+		fmt.Fprintf(os.Stderr, "kustomer-claimsgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// checkNames returns an error if the generated identifiers of the provided
+// claims are invalid or if different claims map to the same identifier.
+func checkNames(claims []*claim) error {
+	goNames := make(map[string]*claim)
+	cNames := make(map[string]*claim)
+	for _, c := range claims {
+		if !token.IsIdentifier(c.GoName) || !token.IsIdentifier("kustomer_claim_get_"+c.CName) {
+			return fmt.Errorf("claim %s.%s has no valid identifier", c.Product, c.Claim)
+		}
+		for _, name := range []string{c.GoName, "Ensure" + c.GoName} {
+			if other, ok := goNames[name]; ok {
+				return fmt.Errorf("claims %s.%s and %s.%s both map to %s", other.Product, other.Claim, c.Product, c.Claim, name)
+			}
+			goNames[name] = c
+		}
+		if other, ok := cNames[c.CName]; ok {
+			return fmt.Errorf("claims %s.%s and %s.%s both map to %s", other.Product, other.Claim, c.Product, c.Claim, c.CName)
+		}
+		cNames[c.CName] = c
+	}
+	return nil
+}
+
+func run(schemaFile, packageName, goOut, cOut string) error {
+	if !token.IsIdentifier(packageName) {
+		return fmt.Errorf("invalid package name: %s", packageName)
+	}
+
+	schemas, err := kustomer.LoadSchemaFile(schemaFile)
+	if err != nil {
+		return err
+	}
+
+	var claims []*claim
+	for _, s := range schemas {
+		for _, cs := range s.Claims {
+			c, claimErr := newClaim(s.Product, cs)
+			if claimErr != nil {
+				return claimErr
+			}
+			claims = append(claims, c)
+		}
+	}
+	if err = checkNames(claims); err != nil {
+		return err
+	}
+
+	if goOut != "" {
+		var b bytes.Buffer
+		if err = goTemplate.Execute(&b, map[string]interface{}{
+			"Package": packageName,
+			"Claims":  claims,
+		}); err != nil {
+			return err
+		}
+		src, formatErr := format.Source(b.Bytes())
+		if formatErr != nil {
+			return formatErr
+		}
+		if err = ioutil.WriteFile(goOut, src, 0644); err != nil {
+			return err
+		}
+	}
+
+	if cOut != "" {
+		var b bytes.Buffer
+		if err = cTemplate.Execute(&b, map[string]interface{}{
+			"Guard":  "KUSTOMER_CLAIMS_" + strings.ToUpper(cName(packageName)) + "_H",
+			"Claims": claims,
+		}); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(cOut, b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
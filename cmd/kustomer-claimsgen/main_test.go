@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRunGolden(t *testing.T) {
+	dir := t.TempDir()
+	goOut := filepath.Join(dir, "claims.go")
+	cOut := filepath.Join(dir, "claims.h")
+	if err := run(filepath.Join("testdata", "schema.yaml"), "claims", goOut, cOut); err != nil {
+		t.Fatal(err)
+	}
+
+	for out, golden := range map[string]string{
+		goOut: filepath.Join("testdata", "claims.go.golden"),
+		cOut:  filepath.Join("testdata", "claims.h.golden"),
+	} {
+		generated, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err = ioutil.WriteFile(golden, generated, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(generated, expected) {
+			t.Errorf("%s does not match %s, run go test -update to update it:\n%s", filepath.Base(out), golden, generated)
+		}
+	}
+}
+
+func TestRunInvalidNames(t *testing.T) {
+	for schema, expected := range map[string]string{
+		"products: [{product: groupware, claims: [{name: max_users, kind: bool}, {name: max-users, kind: bool}]}]": "both map to GroupwareMaxUsers",
+		"products: [{product: groupware, claims: [{name: '---', kind: bool}]}]":                                    "has no valid identifier",
+	} {
+		path := filepath.Join(t.TempDir(), "schema.yaml")
+		if err := ioutil.WriteFile(path, []byte(schema), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := run(path, "claims", filepath.Join(t.TempDir(), "claims.go"), ""); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", schema, expected, err)
+		}
+	}
+
+	if err := run(filepath.Join("testdata", "schema.yaml"), "my-claims", filepath.Join(t.TempDir(), "claims.go"), ""); err == nil {
+		t.Error("expected error for invalid package name")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"max_users":    "MaxUsers",
+		"kopano-meet":  "KopanoMeet",
+		"2fa":          "X2fa",
+		"groupware_2f": "Groupware2f",
+	} {
+		if s := goName(name); s != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, s)
+		}
+	}
+}
@@ -5,6 +5,10 @@
 
 package kustomer
 
+import (
+	"time"
+)
+
 // A Config holds the configuration for this module.
 type Config struct {
 	Logger Logger
@@ -13,4 +17,24 @@ type Config struct {
 	AutoRefresh bool
 
 	ProductUserAgent *string
+
+	// APIPath overrides DefaultAPIPath when not empty. Claims fetched from any
+	// other than the default API path are never trusted. The KUSTOMER_API_PATH
+	// environment variable takes precedence over this value.
+	APIPath string
+
+	// FetchTimeout and RetryInterval override DefaultFetchTimeout and
+	// DefaultRetryInterval when not zero.
+	FetchTimeout  time.Duration
+	RetryInterval time.Duration
+
+	// ExpiryThresholds override DefaultExpiryThresholds when not nil.
+	ExpiryThresholds []time.Duration
+
+	// Clock overrides SystemClock when not nil.
+	Clock Clock
+
+	// AuditHook, when set, is called with every license decision made with
+	// the claims returned by CurrentKopanoProductClaims.
+	AuditHook AuditHook
 }
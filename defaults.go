@@ -5,4 +5,22 @@
 
 package kustomer
 
+import (
+	"time"
+)
+
 var DefaultAPIPath = "/run/kopano-kustomerd/api.sock"
+
+// DefaultFetchTimeout is the maximum duration of a single claims request.
+var DefaultFetchTimeout = 60 * time.Second
+
+// DefaultRetryInterval is the duration to wait before reconnecting or fetching
+// again after an error.
+var DefaultRetryInterval = 5 * time.Second
+
+// DefaultExpiryClaim is the product claim which holds the expiry of a product.
+var DefaultExpiryClaim = "exp"
+
+// DefaultExpiryThresholds are the remaining durations until a product expiry
+// at which ExpiryApproaching events are emitted.
+var DefaultExpiryThresholds = []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}
@@ -6,7 +6,12 @@
 package kustomer
 
 import (
+	"math"
+	"time"
+
 	api "stash.kopano.io/kgol/kustomer/server/api-v1"
+
+	"stash.kopano.io/kc/libkustomer/internal/semver"
 )
 
 // OperatorsType is a special type of strring which can be used as operator.
@@ -18,6 +23,14 @@ const (
 	OperatorGreaterThanOrEqual OperatorType = "ge"
 	OperatorLesserThan         OperatorType = "lt"
 	OperatorLesserThanOrEqual  OperatorType = "le"
+	OperatorEqual              OperatorType = "eq"
+	OperatorNotEqual           OperatorType = "ne"
+
+	OperatorEqualFold OperatorType = "ieq"
+	OperatorHasPrefix OperatorType = "prefix"
+	OperatorHasSuffix OperatorType = "suffix"
+	OperatorGlob      OperatorType = "glob"
+	OperatorRegexp    OperatorType = "regex"
 )
 
 // Claims represent a set of active claim key value pairs.
@@ -39,6 +52,11 @@ type KopanoProductClaims struct {
 
 	mustBeOnline   bool
 	allowUntrusted bool
+
+	generation      uint64
+	auditHook       AuditHook
+	softEnforcement *softEnforcement
+	clock           Clock
 }
 
 // Dump exports the associated KopanoProductClaims data.
@@ -50,9 +68,34 @@ func (kpc *KopanoProductClaims) Dump() map[string]interface{} {
 	}
 }
 
+// WithMustBeOnline returns a view of the associated claims with the
+// mustBeOnline flag set to the provided flag value. If true, any ensure check
+// of the returned claims will fail if the claims data was produced without
+// online verification. The associated claims are not modified, so claims can
+// be shared safely between goroutines.
+func (kpc *KopanoProductClaims) WithMustBeOnline(flag bool) *KopanoProductClaims {
+	derived := *kpc
+	derived.mustBeOnline = flag
+	return &derived
+}
+
+// WithAllowUntrusted returns a view of the associated claims with the
+// allowUntrusted flag set to the provided flag value. If true, any ensure check
+// of the returned claims will ignore the trusted state of the claims data. The
+// associated claims are not modified, so claims can be shared safely between
+// goroutines.
+func (kpc *KopanoProductClaims) WithAllowUntrusted(flag bool) *KopanoProductClaims {
+	derived := *kpc
+	derived.allowUntrusted = flag
+	return &derived
+}
+
 // SetMustBeOnline sets the mustBeOnline flag value of the associated claims
 // to the provided flag value. If true, any ensure check of the associated
 // claims will fail if the claims data was produced without online verification.
+//
+// Deprecated: Use WithMustBeOnline, which does not modify claims which might be
+// in use elsewhere.
 func (kpc *KopanoProductClaims) SetMustBeOnline(flag bool) {
 	kpc.mustBeOnline = flag
 }
@@ -60,14 +103,27 @@ func (kpc *KopanoProductClaims) SetMustBeOnline(flag bool) {
 // SetAllowUntrusted sets the allowUntrusted flag value of the associated claims
 // to the provided flag value. If true, any ensure check of the associated
 // claims will ignore the trusted state of the claims data.
+//
+// Deprecated: Use WithAllowUntrusted, which does not modify claims which might
+// be in use elsewhere.
 func (kpc *KopanoProductClaims) SetAllowUntrusted(flag bool) {
 	kpc.allowUntrusted = flag
 }
 
+// now returns the current time of the clock of the Kustomer instance which
+// provided the associated claims.
+func (kpc *KopanoProductClaims) now() time.Time {
+	if kpc.clock == nil {
+		return SystemClock.Now()
+	}
+	return kpc.clock.Now()
+}
+
 // EnsureOnline returns ErrEnsureOnlineFailed error if the associated claims
 // data was validated with offline. This function returns the error even if
 // the associated claims mustBeOnline flag was is false.
 func (kpc *KopanoProductClaims) EnsureOnline() (err error) {
+	defer kpc.audit("EnsureOnline", "", "", &err)
 	if kpc.response.Offline {
 		return ErrEnsureOnlineFailed
 	}
@@ -78,6 +134,7 @@ func (kpc *KopanoProductClaims) EnsureOnline() (err error) {
 // data is not trusted. This function will return the error even if the
 // associated claims SetAllowUntrusted was set to true.
 func (kpc *KopanoProductClaims) EnsureTrusted() (err error) {
+	defer kpc.audit("EnsureTrusted", "", "", &err)
 	if !kpc.response.Trusted {
 		return ErrEnsureTrustedFailed
 	}
@@ -87,26 +144,28 @@ func (kpc *KopanoProductClaims) EnsureTrusted() (err error) {
 // EnsureOnlineAndTrusted is the combination of EnsureOnline and EnsureOnline
 // for convinience. Samle rules apply as described in those two functions.
 func (kpc *KopanoProductClaims) EnsureOnlineAndTrusted() (err error) {
-	if err := kpc.EnsureOnline(); err != nil {
+	defer kpc.audit("EnsureOnlineAndTrusted", "", "", &err)
+	q := kpc.quiet()
+	if err := q.EnsureOnline(); err != nil {
 		return err
 	}
-	if err := kpc.EnsureTrusted(); err != nil {
+	if err := q.EnsureTrusted(); err != nil {
 		return err
 	}
 	return
 }
 
 func (kpc *KopanoProductClaims) getProduct(product string) (*api.ClaimsKopanoProductsResponseProduct, error) {
-	if err := kpc.EnsureOnline(); kpc.mustBeOnline && err != nil {
-		return nil, err
+	if kpc.mustBeOnline && kpc.response.Offline {
+		return nil, newEnsureError(ErrEnsureOnlineFailed, product, "")
 	}
-	if err := kpc.EnsureTrusted(); !kpc.allowUntrusted && err != nil {
-		return nil, err
+	if !kpc.allowUntrusted && !kpc.response.Trusted {
+		return nil, newEnsureError(ErrEnsureTrustedFailed, product, "")
 	}
 
 	p, ok := kpc.response.Products[product]
 	if !ok {
-		return nil, ErrEnsureProductNotFound
+		return nil, newEnsureError(ErrEnsureProductNotFound, product, "")
 	}
 	return p, nil
 }
@@ -114,15 +173,22 @@ func (kpc *KopanoProductClaims) getProduct(product string) (*api.ClaimsKopanoPro
 func (kpc *KopanoProductClaims) ensureValue(product, claim string) (interface{}, error) {
 	p, err := kpc.getProduct(product)
 	if err != nil {
+		if e, ok := err.(*EnsureError); ok {
+			e.Claim = claim
+		}
 		return nil, err
 	}
 	if !p.OK {
-		return nil, ErrEnsureProductNotLicensed
+		return nil, newEnsureError(ErrEnsureProductNotLicensed, product, claim)
+	}
+
+	if isClaimPath(claim) {
+		return resolveClaimPath(p.Claims, product, claim)
 	}
 
 	v, ok := p.Claims[claim]
 	if !ok {
-		return nil, ErrEnsureProductClaimNotFound
+		return nil, newEnsureError(ErrEnsureProductClaimNotFound, product, claim)
 	}
 
 	return v, nil
@@ -132,251 +198,490 @@ func (kpc *KopanoProductClaims) ensureValue(product, claim string) (interface{},
 // associated claims data or if that product is found but the OK flag of the
 // active product is false.
 func (kpc *KopanoProductClaims) EnsureOK(product string) (err error) {
+	defer kpc.ensured("EnsureOK", product, "", &err)
+	return kpc.ensureOK(product)
+}
+
+func (kpc *KopanoProductClaims) ensureOK(product string) error {
 	p, err := kpc.getProduct(product)
 	if err != nil {
 		return err
 	}
 	if !p.OK {
-		return ErrEnsureProductNotLicensed
+		return newEnsureError(ErrEnsureProductNotLicensed, product, "")
 	}
 	return nil
 }
 
-// GetBool returns the prodvided prodcut claim bool value. If the product or
+// GetBool returns the provided product claim bool value. If the product or
 // the claim is not found, an the returned error describes the reason why the
 // claim value is not available.
 func (kpc *KopanoProductClaims) GetBool(product, claim string) (bool, error) {
-	v, err := kpc.ensureValue(product, claim)
-	if err != nil {
-		return false, err
-	}
-
-	tv, ok := v.(bool)
-	if !ok {
-		return false, ErrEnsureProductClaimValueTypeMismatch
-	}
-	return tv, nil
+	return auditedGet[bool](kpc, "GetBool", product, claim)
 }
 
 // EnsureBool returns an error if the provided product or the claim value is not
 // found. Furthermore the claim value is compared to the provided value and if
 // it is not a match, an error is returned as well.
 func (kpc *KopanoProductClaims) EnsureBool(product, claim string, value bool) error {
-	tv, err := kpc.GetBool(product, claim)
+	return ensure(kpc, "EnsureBool", product, claim, value)
+}
+
+// GetString returns the provided product claim string value. If the product
+// or the claim is not found, an the returned error describes the reason why the
+// claim value is not available.
+func (kpc *KopanoProductClaims) GetString(product, claim string) (string, error) {
+	return auditedGet[string](kpc, "GetString", product, claim)
+}
+
+// EnsureString returns an error if the provided product or the claim value is
+// not found. Furthermore the claim value is compared to the provided value and
+// if it is not a match, an error is returned as well.
+func (kpc *KopanoProductClaims) EnsureString(product, claim, value string) error {
+	return ensure(kpc, "EnsureString", product, claim, value)
+}
+
+// EnsureStringWithOperator returns an error if the provided product or the
+// claim value is not found. Furthermore the claim value is matched against the
+// provided value using the provided string operator and if it is not a match,
+// an error is returned as well. OperatorEqual and OperatorNotEqual compare
+// exactly, OperatorEqualFold compares case-insensitive, OperatorHasPrefix and
+// OperatorHasSuffix check the start and the end of the claim value,
+// OperatorGlob matches shell glob patterns (like *.example.com) and
+// OperatorRegexp matches regular expressions anchored to the whole claim value.
+func (kpc *KopanoProductClaims) EnsureStringWithOperator(product, claim, value string, op OperatorType) (err error) {
+	defer kpc.ensured("EnsureStringWithOperator", product, claim, &err)
+	tv, err := kpc.quiet().GetString(product, claim)
 	if err != nil {
 		return err
 	}
 
-	if value != tv {
-		return ErrEnsureProductClaimValueMismatch
+	matched, err := matchString(op, tv, value)
+	if err != nil {
+		return newEnsureValueError(err.(ErrNumeric), product, claim, op, value, nil)
+	}
+	if !matched {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, op, value, tv)
 	}
 	return nil
 }
 
-// GetString returns the prodvided product claim string value. If the product
+// GetInt64 returns the provided product claim numeric value. If the product
 // or the claim is not found, an the returned error describes the reason why the
 // claim value is not available.
-func (kpc *KopanoProductClaims) GetString(product, claim string) (string, error) {
-	v, err := kpc.ensureValue(product, claim)
+func (kpc *KopanoProductClaims) GetInt64(product, claim string) (int64, error) {
+	return auditedGet[int64](kpc, "GetInt64", product, claim)
+}
+
+// EnsureInt64 returns an error if the provided product or the claim value is
+// not found. Furthermore the claim value is compared to the provided value and
+// if it is not a match, an error is returned as well.
+func (kpc *KopanoProductClaims) EnsureInt64(product, claim string, value int64) error {
+	return ensure(kpc, "EnsureInt64", product, claim, value)
+}
+
+// EnsureInt64WithOperator returns an error if the provided product or the claim
+// value is not found. Furthermore the claim value is compared to the provided
+// value using the provided comparison operator and if it is not a match, an
+// error is returned as well.
+func (kpc *KopanoProductClaims) EnsureInt64WithOperator(product, claim string, value int64, op OperatorType) (err error) {
+	defer kpc.ensured("EnsureInt64WithOperator", product, claim, &err)
+	tv, err := kpc.quiet().GetInt64(product, claim)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	tv, ok := v.(string)
-	if !ok {
-		return "", ErrEnsureProductClaimValueTypeMismatch
+	matched, known := compareWithOperator(tv, value, op)
+	if !known {
+		return newEnsureValueError(ErrEnsureUnknownOperator, product, claim, op, nil, nil)
+	}
+	if !matched {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, op, value, tv)
 	}
-	return tv, nil
+	return nil
 }
 
-// EnsureString returns an error if the provided product or the claim value is
+// GetFloat64 returns the provided product claim float value. If the product
+// or the claim is not found, an the returned error describes the reason why the
+// claim value is not available.
+func (kpc *KopanoProductClaims) GetFloat64(product, claim string) (float64, error) {
+	return auditedGet[float64](kpc, "GetFloat64", product, claim)
+}
+
+// EnsureFloat64 returns an error if the provided product or the claim value is
 // not found. Furthermore the claim value is compared to the provided value and
-// if it is not a match, an error is returned as well.
-func (kpc *KopanoProductClaims) EnsureString(product, claim, value string) error {
-	tv, err := kpc.GetString(product, claim)
+// if it is not an exact match, an error is returned as well. See
+// EnsureFloat64WithTolerance for approximate comparison.
+func (kpc *KopanoProductClaims) EnsureFloat64(product, claim string, value float64) error {
+	return ensure(kpc, "EnsureFloat64", product, claim, value)
+}
+
+// EnsureFloat64WithOperator returns an error if the provided product or the
+// claim value is not found. Furthermore the claim value is compared to the
+// provided value using the provided comparison operator and if it is not a
+// match, an error is returned as well.
+func (kpc *KopanoProductClaims) EnsureFloat64WithOperator(product, claim string, value float64, op OperatorType) (err error) {
+	defer kpc.ensured("EnsureFloat64WithOperator", product, claim, &err)
+	tv, err := kpc.quiet().GetFloat64(product, claim)
 	if err != nil {
 		return err
 	}
 
-	if value != tv {
-		return ErrEnsureProductClaimValueMismatch
+	matched, known := compareWithOperator(tv, value, op)
+	if !known {
+		return newEnsureValueError(ErrEnsureUnknownOperator, product, claim, op, nil, nil)
+	}
+	if !matched {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, op, value, tv)
 	}
 	return nil
 }
 
-// GetInt64 returns the prodvided product claim numeric value. If the product
-// or the claim is not found, an the returned error describes the reason why the
-// claim value is not available.
-func (kpc *KopanoProductClaims) GetInt64(product, claim string) (int64, error) {
-	v, err := kpc.ensureValue(product, claim)
+// EnsureFloat64WithTolerance returns an error if the provided product or the
+// claim value is not found. Furthermore if the claim value differs from the
+// provided value by more than the provided tolerance, an error is returned as
+// well. Use this instead of EnsureFloat64 when the claim value is the result
+// of a calculation. A negative or NaN tolerance returns ErrEnsureInvalidValue.
+func (kpc *KopanoProductClaims) EnsureFloat64WithTolerance(product, claim string, value, tolerance float64) (err error) {
+	defer kpc.ensured("EnsureFloat64WithTolerance", product, claim, &err)
+	if tolerance < 0 || math.IsNaN(tolerance) {
+		return newEnsureValueError(ErrEnsureInvalidValue, product, claim, "", tolerance, nil)
+	}
+	tv, err := kpc.quiet().GetFloat64(product, claim)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	tv, ok := v.(float64)
-	if !ok {
-		return 0, ErrEnsureProductClaimValueTypeMismatch
+	if math.Abs(tv-value) > tolerance {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, OperatorEqual, value, tv)
 	}
-	return int64(tv), nil
+	return nil
 }
 
-// EnsureInt64 returns an error if the provided product or the claim value is
-// not found. Furthermore the claim value is compared to the provided value and
-// if it is not a match, an error is returned as well.
-func (kpc *KopanoProductClaims) EnsureInt64(product, claim string, value int64) error {
-	tv, err := kpc.GetInt64(product, claim)
+// EnsureInt64InRange returns an error if the provided product or the claim
+// value is not found. Furthermore if the claim value is not between the
+// provided min and max values, an error is returned as well. If inclusive is
+// true, min and max are part of the range.
+func (kpc *KopanoProductClaims) EnsureInt64InRange(product, claim string, min, max int64, inclusive bool) (err error) {
+	defer kpc.ensured("EnsureInt64InRange", product, claim, &err)
+	tv, err := kpc.quiet().GetInt64(product, claim)
 	if err != nil {
 		return err
 	}
 
-	if value != tv {
-		return ErrEnsureProductClaimValueMismatch
+	return ensureInRange(product, claim, tv, min, max, inclusive)
+}
+
+// EnsureFloat64InRange returns an error if the provided product or the claim
+// value is not found. Furthermore if the claim value is not between the
+// provided min and max values, an error is returned as well. If inclusive is
+// true, min and max are part of the range.
+func (kpc *KopanoProductClaims) EnsureFloat64InRange(product, claim string, min, max float64, inclusive bool) (err error) {
+	defer kpc.ensured("EnsureFloat64InRange", product, claim, &err)
+	tv, err := kpc.quiet().GetFloat64(product, claim)
+	if err != nil {
+		return err
+	}
+
+	return ensureInRange(product, claim, tv, min, max, inclusive)
+}
+
+func ensureInRange[T int64 | float64](product, claim string, tv, min, max T, inclusive bool) error {
+	lowerOp, upperOp := OperatorGreaterThan, OperatorLesserThan
+	if inclusive {
+		lowerOp, upperOp = OperatorGreaterThanOrEqual, OperatorLesserThanOrEqual
+	}
+
+	if matched, _ := compareWithOperator(tv, min, lowerOp); !matched {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, lowerOp, min, tv)
+	}
+	if matched, _ := compareWithOperator(tv, max, upperOp); !matched {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, upperOp, max, tv)
 	}
 	return nil
 }
 
-// EnsureInt64WithOperator returns an error if the provided product or the claim
-// value is not found. Furthermore the claim value is compared to the provided
-// value using the provided comparison operator and if it is not a match, an
-// error is returned as well.
-func (kpc *KopanoProductClaims) EnsureInt64WithOperator(product, claim string, value int64, op OperatorType) error {
-	tv, err := kpc.GetInt64(product, claim)
+// compareWithOperator compares the provided values using the provided
+// comparison operator. The second return value is false if the operator is
+// not a numeric comparison operator.
+func compareWithOperator[T int64 | float64](tv, value T, op OperatorType) (bool, bool) {
+	switch op {
+	case OperatorGreaterThan:
+		return tv > value, true
+	case OperatorGreaterThanOrEqual:
+		return tv >= value, true
+	case OperatorLesserThan:
+		return tv < value, true
+	case OperatorLesserThanOrEqual:
+		return tv <= value, true
+	case OperatorEqual:
+		return tv == value, true
+	case OperatorNotEqual:
+		return tv != value, true
+	}
+	return false, false
+}
+
+// GetTime returns the provided product claim time value. The claim value must
+// either be a RFC 3339 string or a number of seconds since the unix epoch. If
+// the product or the claim is not found, the returned error describes the
+// reason why the claim value is not available.
+func (kpc *KopanoProductClaims) GetTime(product, claim string) (time.Time, error) {
+	return auditedGet[time.Time](kpc, "GetTime", product, claim)
+}
+
+// GetDuration returns the provided product claim duration value. The claim
+// value must either be a Go duration string or a number of seconds. If the
+// product or the claim is not found, the returned error describes the reason
+// why the claim value is not available.
+func (kpc *KopanoProductClaims) GetDuration(product, claim string) (time.Duration, error) {
+	return auditedGet[time.Duration](kpc, "GetDuration", product, claim)
+}
+
+// EnsureTimeWithOperator returns an error if the provided product or the claim
+// value is not found. Furthermore the claim time value is compared to the
+// provided value using the provided comparison operator and if it is not a
+// match, an error is returned as well. Greater means later in time.
+func (kpc *KopanoProductClaims) EnsureTimeWithOperator(product, claim string, value time.Time, op OperatorType) (err error) {
+	defer kpc.ensured("EnsureTimeWithOperator", product, claim, &err)
+	tv, err := kpc.quiet().GetTime(product, claim)
 	if err != nil {
 		return err
 	}
 
 	switch op {
 	case OperatorGreaterThan:
-		if tv > value {
+		if tv.After(value) {
 			return nil
 		}
 	case OperatorGreaterThanOrEqual:
-		if tv >= value {
+		if !tv.Before(value) {
 			return nil
 		}
 	case OperatorLesserThan:
-		if tv < value {
+		if tv.Before(value) {
 			return nil
 		}
 	case OperatorLesserThanOrEqual:
-		if tv <= value {
+		if !tv.After(value) {
+			return nil
+		}
+	case OperatorEqual:
+		if tv.Equal(value) {
+			return nil
+		}
+	case OperatorNotEqual:
+		if !tv.Equal(value) {
 			return nil
 		}
 	default:
-		return ErrEnsureUnknownOperator
+		return newEnsureValueError(ErrEnsureUnknownOperator, product, claim, op, nil, nil)
 	}
-	return ErrEnsureProductClaimValueMismatch
+	return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, op, value, tv)
 }
 
-// GetFloat64 returns the prodvided product claim float value. If the product
-// or the claim is not found, an the returned error describes the reason why the
-// claim value is not available.
-func (kpc *KopanoProductClaims) GetFloat64(product, claim string) (float64, error) {
-	v, err := kpc.ensureValue(product, claim)
+// EnsureNotExpired returns an error if the provided product or the claim value
+// is not found. Furthermore ErrEnsureProductClaimExpired is returned if the
+// claim time value is not after the provided now. If now is the zero time, the
+// current time of the Clock of the Kustomer instance is used.
+func (kpc *KopanoProductClaims) EnsureNotExpired(product, claim string, now time.Time) (err error) {
+	defer kpc.ensured("EnsureNotExpired", product, claim, &err)
+	tv, err := kpc.quiet().GetTime(product, claim)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	tv, ok := v.(float64)
-	if !ok {
-		return 0, ErrEnsureProductClaimValueTypeMismatch
+	if now.IsZero() {
+		now = kpc.now()
 	}
-	return tv, nil
+	if !tv.After(now) {
+		return newEnsureValueError(ErrEnsureProductClaimExpired, product, claim, OperatorGreaterThan, now, tv)
+	}
+	return nil
 }
 
-// EnsureFloat64 returns an error if the provided product or the claim value is
-// not found. Furthermore the claim value is compared to the provided value and
-// if it is not a match, an error is returned as well.
-func (kpc *KopanoProductClaims) EnsureFloat64(product, claim string, value float64) error {
-	tv, err := kpc.GetFloat64(product, claim)
+// EnsureVersionConstraint returns an error if the provided product or the
+// claim value is not found. Furthermore the claim value is parsed as version
+// constraint expression and if the provided version does not satisfy that
+// constraint, an error is returned as well. Constraint expressions support
+// comparisons (>=11, <12.0.0), caret (^11.1), tilde (~11.1.2), wildcard
+// (11.x), hyphen range (10 - 11.2) and alternatives separated by ||.
+func (kpc *KopanoProductClaims) EnsureVersionConstraint(product, claim, version string) (err error) {
+	defer kpc.ensured("EnsureVersionConstraint", product, claim, &err)
+	v, err := semver.Parse(version)
+	if err != nil {
+		return newEnsureValueError(ErrEnsureInvalidVersion, product, claim, "", nil, version)
+	}
+
+	tv, err := kpc.quiet().GetString(product, claim)
 	if err != nil {
 		return err
 	}
+	c, err := semver.ParseConstraint(tv)
+	if err != nil {
+		return newEnsureValueError(ErrEnsureProductClaimValueTypeMismatch, product, claim, "", "version constraint", tv)
+	}
 
-	if value != tv {
-		return ErrEnsureProductClaimValueMismatch
+	if !c.Check(v) {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", tv, version)
 	}
 	return nil
 }
 
-// EnsureFloat64WithOperator returns an error if the provided product or the
-// claim value is not found. Furthermore the claim value is compared to the
-// provided value using the provided comparison operator and if it is not a
-// match, an error is returned as well.
-func (kpc *KopanoProductClaims) EnsureFloat64WithOperator(product, claim string, value float64, op OperatorType) error {
-	tv, err := kpc.GetFloat64(product, claim)
+// EnsureVersionSatisfies returns an error if the provided product or the claim
+// value is not found. Furthermore the claim value is parsed as version and if
+// it does not satisfy the provided version constraint expression, an error is
+// returned as well. See EnsureVersionConstraint for the constraint syntax.
+func (kpc *KopanoProductClaims) EnsureVersionSatisfies(product, claim, constraint string) (err error) {
+	defer kpc.ensured("EnsureVersionSatisfies", product, claim, &err)
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return newEnsureValueError(ErrEnsureInvalidVersion, product, claim, "", constraint, nil)
+	}
+
+	tv, err := kpc.quiet().GetString(product, claim)
 	if err != nil {
 		return err
 	}
+	v, err := semver.Parse(tv)
+	if err != nil {
+		return newEnsureValueError(ErrEnsureProductClaimValueTypeMismatch, product, claim, "", "version", tv)
+	}
 
-	switch op {
-	case OperatorGreaterThan:
-		if tv > value {
-			return nil
-		}
-	case OperatorGreaterThanOrEqual:
-		if tv >= value {
-			return nil
-		}
-	case OperatorLesserThan:
-		if tv < value {
-			return nil
-		}
-	case OperatorLesserThanOrEqual:
-		if tv <= value {
-			return nil
-		}
-	default:
-		return ErrEnsureUnknownOperator
+	if !c.Check(v) {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", constraint, tv)
 	}
-	return ErrEnsureProductClaimValueMismatch
+	return nil
 }
 
-// GetStringArrayValues returns the prodvided product claim string array value.
+// GetStringArrayValues returns the provided product claim string array value.
 // If the product  or the claim is not found, an the returned error describes
 // the reason why the claim value is not available.
 func (kpc *KopanoProductClaims) GetStringArrayValues(product, claim string) ([]string, error) {
-	v, err := kpc.ensureValue(product, claim)
+	return auditedGet[[]string](kpc, "GetStringArrayValues", product, claim)
+}
+
+// EnsureStringArrayValues returns an error if the provided product or the claim
+// value is not found. Furthermore if not all of the provided value prameters
+// are present in the claim value n error is returned as well.
+func (kpc *KopanoProductClaims) EnsureStringArrayValues(product, claim string, value ...string) error {
+	return ensure(kpc, "EnsureStringArrayValues", product, claim, value)
+}
+
+// GetInt64Array returns the provided product claim numeric array value. If
+// the product or the claim is not found, the returned error describes the
+// reason why the claim value is not available.
+func (kpc *KopanoProductClaims) GetInt64Array(product, claim string) ([]int64, error) {
+	return auditedGet[[]int64](kpc, "GetInt64Array", product, claim)
+}
+
+// GetFloat64Array returns the provided product claim float array value. If
+// the product or the claim is not found, the returned error describes the
+// reason why the claim value is not available.
+func (kpc *KopanoProductClaims) GetFloat64Array(product, claim string) ([]float64, error) {
+	return auditedGet[[]float64](kpc, "GetFloat64Array", product, claim)
+}
+
+// GetObject returns a copy of the provided product claim object value. If the
+// product or the claim is not found, the returned error describes the reason
+// why the claim value is not available.
+func (kpc *KopanoProductClaims) GetObject(product, claim string) (map[string]interface{}, error) {
+	return auditedGet[map[string]interface{}](kpc, "GetObject", product, claim)
+}
+
+// GetStringMap returns the provided product claim object value, which must
+// only have string values. If the product or the claim is not found, the
+// returned error describes the reason why the claim value is not available.
+func (kpc *KopanoProductClaims) GetStringMap(product, claim string) (map[string]string, error) {
+	return auditedGet[map[string]string](kpc, "GetStringMap", product, claim)
+}
+
+// EnsureStringArrayAny returns an error if the provided product or the claim
+// value is not found. Furthermore if none of the provided value parameters is
+// present in the claim value, an error is returned as well.
+func (kpc *KopanoProductClaims) EnsureStringArrayAny(product, claim string, value ...string) (err error) {
+	defer kpc.ensured("EnsureStringArrayAny", product, claim, &err)
+	tv, err := kpc.quiet().GetStringArrayValues(product, claim)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	tvi, ok := v.([]interface{})
-	if !ok {
-		return nil, ErrEnsureProductClaimValueTypeMismatch
-	}
-	tv := make([]string, len(tvi))
-	for i, iv := range tvi {
-		if s, iok := iv.(string); iok {
-			tv[i] = s
-		} else {
-			return nil, ErrEnsureProductClaimValueTypeMismatch
+	set := newStringSet(tv)
+	for _, v := range value {
+		if set[v] {
+			return nil
 		}
 	}
-	return tv, nil
+	return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, tv)
 }
 
-// EnsureStringArrayValues returns an error if the provided product or the claim
-// value is not found. Furthermore if not all of the provided value prameters
-// are present in the claim value n error is returned as well.
-func (kpc *KopanoProductClaims) EnsureStringArrayValues(product, claim string, value ...string) error {
-	tv, err := kpc.GetStringArrayValues(product, claim)
+// EnsureStringArrayNone returns an error if the provided product or the claim
+// value is not found. Furthermore if any of the provided value parameters is
+// present in the claim value, an error is returned as well.
+func (kpc *KopanoProductClaims) EnsureStringArrayNone(product, claim string, value ...string) (err error) {
+	defer kpc.ensured("EnsureStringArrayNone", product, claim, &err)
+	tv, err := kpc.quiet().GetStringArrayValues(product, claim)
 	if err != nil {
 		return err
 	}
 
+	set := newStringSet(tv)
 	for _, v := range value {
-		found := false
-		for _, e := range tv {
-			if v == e {
-				found = true
-				break
-			}
+		if set[v] {
+			return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, v)
 		}
-		if !found {
-			return ErrEnsureProductClaimValueMismatch
+	}
+	return nil
+}
+
+// EnsureStringArrayEquals returns an error if the provided product or the
+// claim value is not found. Furthermore if the claim value and the provided
+// value parameters are not the same set of values, an error is returned as
+// well. Order and duplicates are ignored.
+func (kpc *KopanoProductClaims) EnsureStringArrayEquals(product, claim string, value ...string) (err error) {
+	defer kpc.ensured("EnsureStringArrayEquals", product, claim, &err)
+	tv, err := kpc.quiet().GetStringArrayValues(product, claim)
+	if err != nil {
+		return err
+	}
+
+	set := newStringSet(tv)
+	expected := newStringSet(value)
+	if len(set) != len(expected) {
+		return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, tv)
+	}
+	for v := range expected {
+		if !set[v] {
+			return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, tv)
 		}
 	}
+	return nil
+}
+
+// EnsureStringArraySubsetOf returns an error if the provided product or the
+// claim value is not found. Furthermore if the claim value contains a value
+// which is not one of the provided value parameters, an error is returned as
+// well.
+func (kpc *KopanoProductClaims) EnsureStringArraySubsetOf(product, claim string, value ...string) (err error) {
+	defer kpc.ensured("EnsureStringArraySubsetOf", product, claim, &err)
+	tv, err := kpc.quiet().GetStringArrayValues(product, claim)
+	if err != nil {
+		return err
+	}
 
+	expected := newStringSet(value)
+	for _, v := range tv {
+		if !expected[v] {
+			return newEnsureValueError(ErrEnsureProductClaimValueMismatch, product, claim, "", value, v)
+		}
+	}
 	return nil
 }
+
+func newStringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
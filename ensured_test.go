@@ -0,0 +1,221 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGetTime(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	claims := kpc.response.Products["groupware"].Claims
+	claims["valid_until"] = "2021-06-30T00:00:00Z"
+	claims["issued"] = float64(1609459200)
+	claims["grace"] = "72h"
+
+	validUntil, err := kpc.GetTime("groupware", "valid_until")
+	if err != nil || !validUntil.Equal(time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time value: %v (%v)", validUntil, err)
+	}
+	if err = kpc.EnsureTimeWithOperator("groupware", "issued", validUntil, OperatorLesserThan); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if d, durationErr := kpc.GetDuration("groupware", "grace"); durationErr != nil || d != 72*time.Hour {
+		t.Errorf("unexpected duration value: %v (%v)", d, durationErr)
+	}
+
+	if err = kpc.EnsureNotExpired("groupware", "valid_until", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err = kpc.EnsureNotExpired("groupware", "valid_until", validUntil); !errors.Is(err, ErrEnsureProductClaimExpired) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = kpc.GetTime("groupware", "edition"); !errors.Is(err, ErrEnsureProductClaimValueTypeMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnsureVersion(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	claims := kpc.response.Products["groupware"].Claims
+	claims["supported_versions"] = ">=11.0 <12 || ^10.2"
+	claims["version"] = "11.4.1"
+
+	for version, expected := range map[string]error{
+		"11.0.0":  nil,
+		"10.9.3":  nil,
+		"12.0.0":  ErrEnsureProductClaimValueMismatch,
+		"invalid": ErrEnsureInvalidVersion,
+	} {
+		if err := kpc.EnsureVersionConstraint("groupware", "supported_versions", version); !errors.Is(err, expected) {
+			t.Errorf("%s: unexpected error: %v", version, err)
+		}
+	}
+
+	if err := kpc.EnsureVersionSatisfies("groupware", "version", "~11.4"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := kpc.EnsureVersionSatisfies("groupware", "version", ">=12"); !errors.Is(err, ErrEnsureProductClaimValueMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := kpc.EnsureVersionSatisfies("groupware", "edition", "11.x"); !errors.Is(err, ErrEnsureProductClaimValueTypeMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnsureStringArraySets(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+
+	for _, tc := range []struct {
+		ensure   func(product, claim string, value ...string) error
+		value    []string
+		expected error
+	}{
+		{kpc.EnsureStringArrayAny, []string{"calendar", "webapp"}, nil},
+		{kpc.EnsureStringArrayAny, []string{"calendar"}, ErrEnsureProductClaimValueMismatch},
+		{kpc.EnsureStringArrayNone, []string{"calendar", "meet"}, nil},
+		{kpc.EnsureStringArrayNone, []string{"calendar", "archiver"}, ErrEnsureProductClaimValueMismatch},
+		{kpc.EnsureStringArrayEquals, []string{"webapp", "archiver", "webapp"}, nil},
+		{kpc.EnsureStringArrayEquals, []string{"webapp"}, ErrEnsureProductClaimValueMismatch},
+		{kpc.EnsureStringArraySubsetOf, []string{"webapp", "archiver", "calendar"}, nil},
+		{kpc.EnsureStringArraySubsetOf, []string{"webapp", "calendar"}, ErrEnsureProductClaimValueMismatch},
+	} {
+		if err := tc.ensure("groupware", "features", tc.value...); !errors.Is(err, tc.expected) {
+			t.Errorf("%v: unexpected error: %v", tc.value, err)
+		}
+	}
+}
+
+func TestGetArrayAndObject(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	claims := kpc.response.Products["groupware"].Claims
+	claims["tiers"] = []interface{}{float64(10), float64(100), float64(1000)}
+	claims["ratios"] = []interface{}{0.5, 1.5}
+	claims["limits"] = map[string]interface{}{"archiver": float64(50), "webapp": "unlimited", "quota": map[string]interface{}{"mail": "10G"}}
+	claims["labels"] = map[string]interface{}{"region": "eu"}
+
+	if tiers, err := kpc.GetInt64Array("groupware", "tiers"); err != nil || len(tiers) != 3 || tiers[2] != 1000 {
+		t.Errorf("unexpected int64 array value: %v (%v)", tiers, err)
+	}
+	if ratios, err := kpc.GetFloat64Array("groupware", "ratios"); err != nil || len(ratios) != 2 || ratios[1] != 1.5 {
+		t.Errorf("unexpected float64 array value: %v (%v)", ratios, err)
+	}
+	if limits, err := kpc.GetObject("groupware", "limits"); err != nil || limits["webapp"] != "unlimited" {
+		t.Errorf("unexpected object value: %v (%v)", limits, err)
+	}
+	if limits, err := kpc.GetObject("groupware", "limits"); err == nil {
+		limits["webapp"] = "none"
+		limits["quota"].(map[string]interface{})["mail"] = "0"
+	}
+	if limits := claims["limits"].(map[string]interface{}); limits["webapp"] != "unlimited" || limits["quota"].(map[string]interface{})["mail"] != "10G" {
+		t.Errorf("expected object value to be a copy, claims changed to %v", limits)
+	}
+	if labels, err := kpc.GetStringMap("groupware", "labels"); err != nil || labels["region"] != "eu" {
+		t.Errorf("unexpected string map value: %v (%v)", labels, err)
+	}
+	if err := Ensure(kpc, "groupware", "tiers", []int64{10, 100, 1000}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, err := range []error{
+		func() error { _, err := kpc.GetInt64Array("groupware", "features"); return err }(),
+		func() error { _, err := kpc.GetObject("groupware", "tiers"); return err }(),
+		func() error { _, err := kpc.GetStringMap("groupware", "limits"); return err }(),
+	} {
+		if !errors.Is(err, ErrEnsureProductClaimValueTypeMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestNumericOperators(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	a, b := 0.1, 0.2
+	kpc.response.Products["groupware"].Claims["ratio"] = a + b
+
+	for _, err := range []error{
+		kpc.EnsureInt64WithOperator("groupware", "max_users", 100, OperatorEqual),
+		kpc.EnsureInt64WithOperator("groupware", "max_users", 50, OperatorNotEqual),
+		kpc.EnsureInt64InRange("groupware", "max_users", 100, 200, true),
+		kpc.EnsureFloat64InRange("groupware", "ratio", 0, 1, false),
+		kpc.EnsureFloat64WithTolerance("groupware", "ratio", 0.3, 1e-9),
+	} {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	for _, err := range []error{
+		kpc.EnsureInt64WithOperator("groupware", "max_users", 100, OperatorNotEqual),
+		kpc.EnsureInt64InRange("groupware", "max_users", 100, 200, false),
+		kpc.EnsureInt64InRange("groupware", "max_users", 0, 99, true),
+		kpc.EnsureFloat64("groupware", "ratio", 0.3),
+		kpc.EnsureFloat64WithTolerance("groupware", "ratio", 0.31, 1e-9),
+	} {
+		if !errors.Is(err, ErrEnsureProductClaimValueMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	for _, tolerance := range []float64{-1e-9, math.NaN()} {
+		if err := kpc.EnsureFloat64WithTolerance("groupware", "ratio", 0.3, tolerance); !errors.Is(err, ErrEnsureInvalidValue) {
+			t.Errorf("unexpected error for tolerance %v: %v", tolerance, err)
+		}
+	}
+}
+
+func TestGetInt64Exact(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	claims := kpc.response.Products["groupware"].Claims
+	claims["quota"] = json.Number("9007199254740993")
+	claims["ratio"] = json.Number("2.5")
+	claims["huge"] = json.Number("1e30")
+	claims["fraction"] = 1.5
+
+	if v, err := kpc.GetInt64("groupware", "quota"); err != nil || v != 9007199254740993 {
+		t.Errorf("unexpected value: %v (%v)", v, err)
+	}
+	if v, err := kpc.GetFloat64("groupware", "ratio"); err != nil || v != 2.5 {
+		t.Errorf("unexpected value: %v (%v)", v, err)
+	}
+	for _, claim := range []string{"ratio", "huge", "fraction"} {
+		if _, err := kpc.GetInt64("groupware", claim); !errors.Is(err, ErrEnsureProductClaimValueNotInteger) {
+			t.Errorf("%s: unexpected error: %v", claim, err)
+		}
+	}
+	if err := kpc.EnsureExpr(`groupware.ratio > 2`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWithFlags(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Trusted = false
+	kpc.response.Offline = true
+
+	if err := kpc.EnsureOK("groupware"); !errors.Is(err, ErrEnsureTrustedFailed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	allowUntrusted := kpc.WithAllowUntrusted(true)
+	if err := allowUntrusted.EnsureOK("groupware"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := allowUntrusted.WithMustBeOnline(true).EnsureOK("groupware"); !errors.Is(err, ErrEnsureOnlineFailed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Derived views do not modify the original claims.
+	if err := kpc.EnsureOK("groupware"); !errors.Is(err, ErrEnsureTrustedFailed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := allowUntrusted.EnsureOK("groupware"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// An EnsureError is the error returned by the ensure functions of
+// KopanoProductClaims. It wraps the ErrNumeric reason, so errors.Is and
+// errors.As can be used to check for a specific ErrNumeric, and records the
+// details of the failing check. Fields which do not apply to the failing check
+// are left empty.
+type EnsureError struct {
+	Err      ErrNumeric
+	Product  string
+	Claim    string
+	Operator OperatorType
+	Expected interface{}
+	Actual   interface{}
+}
+
+func newEnsureError(err ErrNumeric, product, claim string) *EnsureError {
+	return &EnsureError{
+		Err:     err,
+		Product: product,
+		Claim:   claim,
+	}
+}
+
+func newEnsureValueError(err ErrNumeric, product, claim string, op OperatorType, expected, actual interface{}) *EnsureError {
+	return &EnsureError{
+		Err:      err,
+		Product:  product,
+		Claim:    claim,
+		Operator: op,
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+func (e *EnsureError) Error() string {
+	var details []string
+	if e.Product != "" {
+		details = append(details, fmt.Sprintf("product=%q", e.Product))
+	}
+	if e.Claim != "" {
+		details = append(details, fmt.Sprintf("claim=%q", e.Claim))
+	}
+	if e.Operator != "" {
+		details = append(details, fmt.Sprintf("operator=%s", e.Operator))
+	}
+	if e.Expected != nil {
+		details = append(details, fmt.Sprintf("expected=%v", e.Expected))
+	}
+	if e.Actual != nil {
+		details = append(details, fmt.Sprintf("actual=%v", e.Actual))
+	}
+	if len(details) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s [%s]", e.Err.Error(), strings.Join(details, " "))
+}
+
+// Unwrap returns the ErrNumeric reason of the associated error.
+func (e *EnsureError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (e *EnsureError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Code     uint64       `json:"code"`
+		Message  string       `json:"message"`
+		Product  string       `json:"product,omitempty"`
+		Claim    string       `json:"claim,omitempty"`
+		Operator OperatorType `json:"operator,omitempty"`
+		Expected interface{}  `json:"expected,omitempty"`
+		Actual   interface{}  `json:"actual,omitempty"`
+	}{
+		Code:     uint64(e.Err),
+		Message:  ErrNumericText(e.Err),
+		Product:  e.Product,
+		Claim:    e.Claim,
+		Operator: e.Operator,
+		Expected: e.Expected,
+		Actual:   e.Actual,
+	})
+}
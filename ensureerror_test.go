@@ -0,0 +1,37 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEnsureError(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+
+	err := kpc.EnsureInt64WithOperator("groupware", "max_users", 500, OperatorGreaterThanOrEqual)
+	if !errors.Is(err, ErrEnsureProductClaimValueMismatch) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ensureErr *EnsureError
+	if !errors.As(err, &ensureErr) {
+		t.Fatalf("expected EnsureError, got %T", err)
+	}
+	if ensureErr.Product != "groupware" || ensureErr.Claim != "max_users" || ensureErr.Expected != int64(500) || ensureErr.Actual != int64(100) {
+		t.Errorf("unexpected error details: %+v", ensureErr)
+	}
+
+	b, err := json.Marshal(ensureErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"code":65543,"message":"Ensure failed, product claim value mismatch","product":"groupware","claim":"max_users","operator":"ge","expected":500,"actual":100}`
+	if string(b) != expected {
+		t.Errorf("unexpected JSON: %s", b)
+	}
+}
@@ -6,6 +6,7 @@
 package kustomer
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -25,6 +26,9 @@ const (
 	ErrStatusAlreadyInitialized
 	ErrStatusNotInitialized
 	ErrStatusTimeout
+	ErrStatusLicenseNotFound
+	ErrStatusClaimNotFound
+	ErrStatusInvalidConfig
 )
 
 // StatusSuccess is the success response as returned by this library.
@@ -41,6 +45,13 @@ const (
 	ErrEnsureProductClaimValueMismatch
 	ErrEnsureUnknownOperator
 	ErrEnsureInvalidTransaction
+	ErrEnsureInvalidExpression
+	ErrEnsureProductClaimExpired
+	ErrEnsureInvalidVersion
+	ErrEnsureInvalidPattern
+	ErrEnsureInvalidValue
+	ErrEnsureProductClaimValueNotInteger
+	ErrEnsureInvalidSnapshot
 )
 
 // ErrNumericToTextMap maps numeric errors to readable names.
@@ -50,6 +61,9 @@ var ErrNumericToTextMap = map[ErrNumeric]string{
 	ErrStatusAlreadyInitialized: "Already Initialized",
 	ErrStatusNotInitialized:     "Not Initialized",
 	ErrStatusTimeout:            "Timeout",
+	ErrStatusLicenseNotFound:    "License Not Found",
+	ErrStatusClaimNotFound:      "Claim Not Found",
+	ErrStatusInvalidConfig:      "Invalid Configuration",
 
 	ErrEnsureOnlineFailed:                  "Ensure failed, product claim set not online",
 	ErrEnsureTrustedFailed:                 "Ensure failed, product claim set not trusted",
@@ -60,6 +74,13 @@ var ErrNumericToTextMap = map[ErrNumeric]string{
 	ErrEnsureProductClaimValueMismatch:     "Ensure failed, product claim value mismatch",
 	ErrEnsureUnknownOperator:               "Ensure failed, unknown operator",
 	ErrEnsureInvalidTransaction:            "Ensure failed, invalid transaction",
+	ErrEnsureInvalidExpression:             "Ensure failed, invalid expression",
+	ErrEnsureProductClaimExpired:           "Ensure failed, product claim time has expired",
+	ErrEnsureInvalidVersion:                "Ensure failed, invalid version or version constraint",
+	ErrEnsureInvalidPattern:                "Ensure failed, invalid match pattern",
+	ErrEnsureInvalidValue:                  "Ensure failed, invalid value",
+	ErrEnsureProductClaimValueNotInteger:   "Ensure failed, product claim value is not an integer or out of range",
+	ErrEnsureInvalidSnapshot:               "Ensure failed, invalid or forged snapshot",
 }
 
 // ErrNumericText returns a text for the ErrStatus. It returns the empty string
@@ -68,3 +89,118 @@ func ErrNumericText(code ErrNumeric) string {
 	text := ErrNumericToTextMap[code]
 	return text
 }
+
+// ErrCategory is the category of an ErrNumeric.
+type ErrCategory string
+
+// Categories of numeric errors.
+const (
+	ErrCategoryNone   ErrCategory = ""
+	ErrCategoryStatus ErrCategory = "status"
+	ErrCategoryEnsure ErrCategory = "ensure"
+)
+
+type errNumericFlags uint8
+
+const (
+	// errFlagRetryable marks errors which might not happen when trying again
+	// later, without any change by the caller.
+	errFlagRetryable errNumericFlags = 1 << iota
+	// errFlagDenial marks errors which mean that the license data was
+	// evaluated and does not allow what was checked.
+	errFlagDenial
+	// errFlagInfrastructure marks errors which mean that the license data
+	// could not be obtained or cannot be relied upon.
+	errFlagInfrastructure
+)
+
+// errNumericFlagsMap maps numeric errors to their flags. Errors without flags
+// are usage errors, like invalid parameters.
+var errNumericFlagsMap = map[ErrNumeric]errNumericFlags{
+	ErrStatusUnknown:         errFlagInfrastructure,
+	ErrStatusNotInitialized:  errFlagInfrastructure,
+	ErrStatusTimeout:         errFlagInfrastructure | errFlagRetryable,
+	ErrStatusLicenseNotFound: errFlagDenial,
+	ErrStatusClaimNotFound:   errFlagDenial,
+
+	ErrEnsureOnlineFailed:                  errFlagInfrastructure | errFlagRetryable,
+	ErrEnsureTrustedFailed:                 errFlagInfrastructure,
+	ErrEnsureProductNotFound:               errFlagDenial,
+	ErrEnsureProductNotLicensed:            errFlagDenial,
+	ErrEnsureProductClaimNotFound:          errFlagDenial,
+	ErrEnsureProductClaimValueTypeMismatch: errFlagDenial,
+	ErrEnsureProductClaimValueMismatch:     errFlagDenial,
+	ErrEnsureProductClaimExpired:           errFlagDenial,
+	ErrEnsureProductClaimValueNotInteger:   errFlagDenial,
+	ErrEnsureInvalidSnapshot:               errFlagInfrastructure,
+}
+
+// Category returns the category of the associated error, ErrCategoryStatus for
+// the ErrStatus* codes and ErrCategoryEnsure for the ErrEnsure* codes.
+// StatusSuccess has no category.
+func (errStatus ErrNumeric) Category() ErrCategory {
+	switch {
+	case errStatus >= 1<<16:
+		return ErrCategoryEnsure
+	case errStatus >= 1<<8:
+		return ErrCategoryStatus
+	}
+	return ErrCategoryNone
+}
+
+// Retryable returns true if the associated error might not happen when trying
+// again later, for example after a timeout or while the claims are offline.
+func (errStatus ErrNumeric) Retryable() bool {
+	return errNumericFlagsMap[errStatus]&errFlagRetryable != 0
+}
+
+// Denial returns true if the associated error means that the license data was
+// evaluated and does not allow what was checked.
+func (errStatus ErrNumeric) Denial() bool {
+	return errNumericFlagsMap[errStatus]&errFlagDenial != 0
+}
+
+// Infrastructure returns true if the associated error means that the license
+// data could not be obtained or cannot be relied upon. Errors which are
+// neither denials nor infrastructure failures are usage errors, like invalid
+// parameters.
+func (errStatus ErrNumeric) Infrastructure() bool {
+	return errNumericFlagsMap[errStatus]&errFlagInfrastructure != 0
+}
+
+type errNumericJSON struct {
+	Code    uint64 `json:"code"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. Numeric errors are
+// marshaled as object with code, name and message.
+func (errStatus ErrNumeric) MarshalJSON() ([]byte, error) {
+	name := errStatus.String()
+	if errStatus == StatusSuccess {
+		name = "StatusSuccess"
+	}
+	return json.Marshal(&errNumericJSON{
+		Code:    uint64(errStatus),
+		Name:    name,
+		Message: ErrNumericText(errStatus),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It accepts the
+// object form of MarshalJSON, of which only the code is used, as well as a
+// plain number.
+func (errStatus *ErrNumeric) UnmarshalJSON(data []byte) error {
+	var code uint64
+	if err := json.Unmarshal(data, &code); err == nil {
+		*errStatus = ErrNumeric(code)
+		return nil
+	}
+	var v errNumericJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*errStatus = ErrNumeric(v.Code)
+	return nil
+}
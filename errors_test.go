@@ -5,10 +5,133 @@
 
 package kustomer
 
-import "testing"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
 
 func TestErrors(t *testing.T) {
 	for err := range ErrNumericToTextMap {
 		t.Logf("%d: %s", err, err)
 	}
 }
+
+// errNumericRegistry is the frozen list of all numeric error codes. C and PHP
+// consumers persist these codes, so existing entries must never change. New
+// codes must be appended here when they are added.
+var errNumericRegistry = []struct {
+	err  ErrNumeric
+	code uint64
+}{
+	{ErrStatusUnknown, 0x101},
+	{ErrStatusInvalidProductName, 0x102},
+	{ErrStatusAlreadyInitialized, 0x103},
+	{ErrStatusNotInitialized, 0x104},
+	{ErrStatusTimeout, 0x105},
+	{ErrStatusLicenseNotFound, 0x106},
+	{ErrStatusClaimNotFound, 0x107},
+	{ErrStatusInvalidConfig, 0x108},
+
+	{ErrEnsureOnlineFailed, 0x10001},
+	{ErrEnsureTrustedFailed, 0x10002},
+	{ErrEnsureProductNotFound, 0x10003},
+	{ErrEnsureProductNotLicensed, 0x10004},
+	{ErrEnsureProductClaimNotFound, 0x10005},
+	{ErrEnsureProductClaimValueTypeMismatch, 0x10006},
+	{ErrEnsureProductClaimValueMismatch, 0x10007},
+	{ErrEnsureUnknownOperator, 0x10008},
+	{ErrEnsureInvalidTransaction, 0x10009},
+	{ErrEnsureInvalidExpression, 0x1000a},
+	{ErrEnsureProductClaimExpired, 0x1000b},
+	{ErrEnsureInvalidVersion, 0x1000c},
+	{ErrEnsureInvalidPattern, 0x1000d},
+	{ErrEnsureInvalidValue, 0x1000e},
+	{ErrEnsureProductClaimValueNotInteger, 0x1000f},
+	{ErrEnsureInvalidSnapshot, 0x10010},
+}
+
+func TestErrNumericRegistry(t *testing.T) {
+	if StatusSuccess != 0 {
+		t.Errorf("StatusSuccess changed to 0x%x", uint64(StatusSuccess))
+	}
+
+	registered := make(map[ErrNumeric]bool)
+	for _, entry := range errNumericRegistry {
+		if uint64(entry.err) != entry.code {
+			t.Errorf("code of %s changed from 0x%x to 0x%x", ErrNumericText(entry.err), entry.code, uint64(entry.err))
+		}
+		if ErrNumericText(entry.err) == "" {
+			t.Errorf("code 0x%x has no text", entry.code)
+		}
+		if name := entry.err.String(); !strings.HasPrefix(name, "Err") {
+			t.Errorf("code 0x%x has no name: %s", entry.code, name)
+		}
+		registered[entry.err] = true
+	}
+	for err := range ErrNumericToTextMap {
+		if !registered[err] {
+			t.Errorf("code 0x%x (%s) is missing in the registry", uint64(err), ErrNumericText(err))
+		}
+	}
+	for err := range errNumericFlagsMap {
+		if !registered[err] {
+			t.Errorf("flagged code 0x%x is missing in the registry", uint64(err))
+		}
+	}
+}
+
+func TestErrNumericProperties(t *testing.T) {
+	for _, tc := range []struct {
+		err            ErrNumeric
+		category       ErrCategory
+		retryable      bool
+		denial         bool
+		infrastructure bool
+	}{
+		{StatusSuccess, ErrCategoryNone, false, false, false},
+		{ErrStatusTimeout, ErrCategoryStatus, true, false, true},
+		{ErrStatusInvalidProductName, ErrCategoryStatus, false, false, false},
+		{ErrEnsureOnlineFailed, ErrCategoryEnsure, true, false, true},
+		{ErrEnsureTrustedFailed, ErrCategoryEnsure, false, false, true},
+		{ErrEnsureProductNotLicensed, ErrCategoryEnsure, false, true, false},
+		{ErrEnsureInvalidExpression, ErrCategoryEnsure, false, false, false},
+	} {
+		if tc.err.Category() != tc.category || tc.err.Retryable() != tc.retryable ||
+			tc.err.Denial() != tc.denial || tc.err.Infrastructure() != tc.infrastructure {
+			t.Errorf("unexpected properties of 0x%x: %s %v %v %v", uint64(tc.err),
+				tc.err.Category(), tc.err.Retryable(), tc.err.Denial(), tc.err.Infrastructure())
+		}
+	}
+	for _, entry := range errNumericRegistry {
+		if entry.err.Denial() && entry.err.Infrastructure() {
+			t.Errorf("code 0x%x is both denial and infrastructure failure", entry.code)
+		}
+	}
+}
+
+func TestErrNumericJSON(t *testing.T) {
+	b, err := json.Marshal(ErrEnsureProductClaimExpired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["code"] != float64(ErrEnsureProductClaimExpired) || v["name"] != "ErrEnsureProductClaimExpired" ||
+		v["message"] != ErrNumericText(ErrEnsureProductClaimExpired) {
+		t.Errorf("unexpected JSON: %s", b)
+	}
+	if b, _ = json.Marshal(ErrNumeric(StatusSuccess)); !strings.Contains(string(b), `"name":"StatusSuccess"`) {
+		t.Errorf("unexpected JSON of StatusSuccess: %s", b)
+	}
+
+	var restored ErrNumeric
+	if err = json.Unmarshal(b, &restored); err != nil || restored != StatusSuccess {
+		t.Errorf("unexpected round trip: 0x%x (%v)", uint64(restored), err)
+	}
+	if err = json.Unmarshal([]byte("257"), &restored); err != nil || restored != ErrStatusUnknown {
+		t.Errorf("unexpected plain number: 0x%x (%v)", uint64(restored), err)
+	}
+}
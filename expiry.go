@@ -0,0 +1,197 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"sort"
+	"time"
+)
+
+// An ExpiryApproaching event is emitted when the time until the earliest
+// expiry of a product falls below one of the configured thresholds.
+type ExpiryApproaching struct {
+	Product   string
+	Expiry    time.Time
+	Remaining time.Duration
+	Threshold time.Duration
+}
+
+// Expiry returns the earliest expiry of the provided product. The expiry is
+// read from the DefaultExpiryClaim claim of the product, which can either be
+// a single time value or, for products aggregated from multiple licenses, an
+// array of time values. Time values are read like with GetTime.
+func (kpc *KopanoProductClaims) Expiry(product string) (time.Time, error) {
+	var expiry time.Time
+
+	v, err := kpc.quiet().ensureValue(product, DefaultExpiryClaim)
+	if err != nil {
+		return expiry, err
+	}
+
+	if values, isArray := v.([]interface{}); isArray {
+		for _, av := range values {
+			var t time.Time
+			if code := convertClaimValue(av, &t); code != StatusSuccess {
+				return expiry, newEnsureValueError(code, product, DefaultExpiryClaim, "", "time.Time", av)
+			}
+			if expiry.IsZero() || t.Before(expiry) {
+				expiry = t
+			}
+		}
+		if expiry.IsZero() {
+			return expiry, newEnsureError(ErrEnsureProductClaimNotFound, product, DefaultExpiryClaim)
+		}
+		return expiry, nil
+	}
+
+	if code := convertClaimValue(v, &expiry); code != StatusSuccess {
+		return expiry, newEnsureValueError(code, product, DefaultExpiryClaim, "", "time.Time", v)
+	}
+	return expiry, nil
+}
+
+// Expiries returns the earliest expiry of all licensed products which have an
+// expiry. See Expiry for details.
+func (kpc *KopanoProductClaims) Expiries() map[string]time.Time {
+	expiries := make(map[string]time.Time)
+	for product, p := range kpc.response.Products {
+		if !p.OK {
+			continue
+		}
+		if expiry, err := kpc.Expiry(product); err == nil {
+			expiries[product] = expiry
+		}
+	}
+	return expiries
+}
+
+// OnExpiryApproaching adds the provided handler, which is called with every
+// ExpiryApproaching event of the associated instance. For each product expiry,
+// an event is emitted once per threshold. If multiple thresholds are crossed at
+// once, only the event of the smallest crossed threshold is emitted. Events are
+// only scheduled over time if AutoRefresh is enabled, otherwise they are only
+// checked when claims have been fetched.
+func (k *Kustomer) OnExpiryApproaching(handler func(*ExpiryApproaching)) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.expiryHandlers = append(k.expiryHandlers, handler)
+}
+
+type expiryThresholdKey struct {
+	product   string
+	expiry    int64 // Unix nanoseconds, as == on time.Time compares locations.
+	threshold time.Duration
+}
+
+// expiryTracker remembers which ExpiryApproaching events have been emitted. It
+// is only used by the fetch loop of a Kustomer instance.
+type expiryTracker struct {
+	thresholds []time.Duration
+	emitted    map[expiryThresholdKey]bool
+}
+
+func newExpiryTracker(thresholds []time.Duration) *expiryTracker {
+	sorted := make([]time.Duration, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		if threshold > 0 {
+			sorted = append(sorted, threshold)
+		}
+	}
+	// Largest threshold first.
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	return &expiryTracker{
+		thresholds: sorted,
+		emitted:    make(map[expiryThresholdKey]bool),
+	}
+}
+
+// check returns the events which are due at the provided time for the provided
+// expiries. It also returns the next time at which check needs to run again,
+// which is either the next threshold or the earliest expiry, and whether that
+// time is an expiry. The next time is zero if there is nothing to schedule.
+func (t *expiryTracker) check(expiries map[string]time.Time, now time.Time) ([]*ExpiryApproaching, time.Time, bool) {
+	var events []*ExpiryApproaching
+	var next time.Time
+	atExpiry := false
+	schedule := func(at time.Time, isExpiry bool) {
+		if next.IsZero() || at.Before(next) || (at.Equal(next) && isExpiry) {
+			next, atExpiry = at, isExpiry
+		}
+	}
+
+	products := make([]string, 0, len(expiries))
+	for product := range expiries {
+		products = append(products, product)
+	}
+	sort.Strings(products)
+
+	for _, product := range products {
+		expiry := expiries[product]
+		remaining := expiry.Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+		schedule(expiry, true)
+
+		var due *ExpiryApproaching
+		for _, threshold := range t.thresholds {
+			key := expiryThresholdKey{product, expiry.UnixNano(), threshold}
+			if remaining > threshold {
+				schedule(expiry.Add(-threshold), false)
+				continue
+			}
+			if t.emitted[key] {
+				continue
+			}
+			t.emitted[key] = true
+			due = &ExpiryApproaching{
+				Product:   product,
+				Expiry:    expiry,
+				Remaining: remaining,
+				Threshold: threshold,
+			}
+		}
+		if due != nil {
+			events = append(events, due)
+		}
+	}
+
+	for key := range t.emitted {
+		if key.expiry <= now.UnixNano() {
+			delete(t.emitted, key)
+		}
+	}
+
+	return events, next, atExpiry
+}
+
+// checkExpiry emits all due ExpiryApproaching events for the provided claims
+// and returns the next time to check again. See expiryTracker.check.
+func (k *Kustomer) checkExpiry(tracker *expiryTracker, kpc *KopanoProductClaims, now time.Time) (time.Time, bool) {
+	events, next, atExpiry := tracker.check(kpc.Expiries(), now)
+	if len(events) == 0 {
+		return next, atExpiry
+	}
+
+	k.mutex.RLock()
+	handlers := k.expiryHandlers
+	debug := k.debug
+	logger := k.logger
+	k.mutex.RUnlock()
+
+	for _, event := range events {
+		if debug {
+			logger.Printf("libkustomer product %s expires in %v at %v\n", event.Product, event.Remaining, event.Expiry)
+		}
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+	return next, atExpiry
+}
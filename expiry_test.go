@@ -0,0 +1,164 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Products["groupware"].Claims["exp"] = []interface{}{
+		now.Add(10 * 24 * time.Hour).Format(time.RFC3339),
+		float64(now.Add(3 * 24 * time.Hour).Unix()),
+	}
+	kpc.response.Products["meet"].Claims["exp"] = now.Add(time.Hour).Format(time.RFC3339)
+
+	expiry, err := kpc.Expiry("groupware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(now.Add(3 * 24 * time.Hour)) {
+		t.Errorf("expected earliest expiry, got %v", expiry)
+	}
+	expiries := kpc.Expiries()
+	if len(expiries) != 1 {
+		t.Errorf("expected only licensed products, got %v", expiries)
+	}
+
+	tracker := newExpiryTracker([]time.Duration{24 * time.Hour, 30 * 24 * time.Hour, 7 * 24 * time.Hour})
+	events, next, atExpiry := tracker.check(expiries, now)
+	if len(events) != 1 || events[0].Threshold != 7*24*time.Hour || events[0].Remaining != 3*24*time.Hour {
+		t.Fatalf("expected single 7 day event, got %v", events)
+	}
+	if !next.Equal(expiry.Add(-24*time.Hour)) || atExpiry {
+		t.Errorf("expected next check at 1 day threshold, got %v (%v)", next, atExpiry)
+	}
+
+	events, _, _ = tracker.check(expiries, now.Add(time.Hour))
+	if len(events) != 0 {
+		t.Errorf("expected no repeated events, got %v", events)
+	}
+
+	events, next, atExpiry = tracker.check(expiries, next)
+	if len(events) != 1 || events[0].Threshold != 24*time.Hour {
+		t.Errorf("expected 1 day event, got %v", events)
+	}
+	if !next.Equal(expiry) || !atExpiry {
+		t.Errorf("expected next check at expiry, got %v (%v)", next, atExpiry)
+	}
+
+	events, next, _ = tracker.check(expiries, expiry)
+	if len(events) != 0 || !next.IsZero() {
+		t.Errorf("expected nothing after expiry, got %v %v", events, next)
+	}
+}
+
+func TestExpiryFetchLoop(t *testing.T) {
+	dir, err := os.MkdirTemp("", "kustomer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	apiPath := filepath.Join(dir, "api.sock")
+	listener, err := net.Listen("unix", apiPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := NewFakeClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	expiry := clock.Now().Add(2 * time.Hour)
+
+	var fetches int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/claims/watch", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(rw, "event: hello\ndata: {}\n\n")
+		rw.(http.Flusher).Flush()
+		<-req.Context().Done()
+	})
+	mux.HandleFunc("/api/v1/claims/kopano/products", func(rw http.ResponseWriter, req *http.Request) {
+		// The refetch at expiry returns a renewed license.
+		exp := expiry
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			exp = expiry.Add(24 * time.Hour)
+		}
+		fmt.Fprintf(rw, `{"trusted": true, "offline": false, "products": {"groupware": {"ok": true, "claims": {"exp": %d}}}}`, exp.Unix())
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener) //nolint:errcheck
+	defer server.Close()
+
+	t.Setenv("KUSTOMER_API_PATH", "")
+	k, err := New(&Config{
+		Logger:           DefaultLogger,
+		AutoRefresh:      true,
+		APIPath:          apiPath,
+		ExpiryThresholds: []time.Duration{time.Hour},
+		Clock:            clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *ExpiryApproaching, 4)
+	k.OnExpiryApproaching(func(event *ExpiryApproaching) {
+		events <- event
+	})
+	if err = k.Initialize(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer k.Uninitialize() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = k.WaitUntilReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForTimer := func() {
+		for clock.Waiters() == 0 {
+			select {
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for fetch loop timer")
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	// At the threshold, the event is emitted without fetching again.
+	waitForTimer()
+	clock.Advance(time.Hour)
+	select {
+	case event := <-events:
+		if event.Product != "groupware" || event.Threshold != time.Hour || !event.Expiry.Equal(expiry) {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for expiry approaching event")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected single fetch before expiry, got %d", n)
+	}
+
+	// At expiry, claims are fetched again.
+	waitForTimer()
+	clock.Advance(time.Hour)
+	for atomic.LoadInt32(&fetches) < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for fetch at expiry")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,585 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An Expr is a parsed policy expression which can be evaluated against
+// KopanoProductClaims. Expressions combine terms with &&, || and ! and
+// parentheses. Terms are either boolean values or comparisons using ==, !=,
+// >, >=, <, <= or in. Values are:
+//
+//	online, trusted          state of the claims data
+//	product.ok               the OK flag of a product
+//	product.claim            the value of a product claim
+//	product./limits/users    a JSON Pointer path into a product claim
+//	"text", 50, 1.5          string and number literals
+//	true, false              boolean literals
+//
+// The in operator checks if a string is contained in a string array claim,
+// for example `"archiver" in groupware.features`. Claim names and JSON
+// Pointer reference tokens can only contain letters, digits, _, -, . and ~
+// escapes. Other claims can only be checked with the Ensure* functions.
+type Expr struct {
+	source string
+	root   exprNode
+}
+
+// ParseExpr parses the provided expression. ErrEnsureInvalidExpression is
+// returned if the expression cannot be parsed.
+func ParseExpr(expression string) (*Expr, error) {
+	p := &exprParser{
+		source: expression,
+	}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, ErrEnsureInvalidExpression
+	}
+
+	return &Expr{
+		source: expression,
+		root:   root,
+	}, nil
+}
+
+// String returns the source of the associated expression.
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Ensure evaluates the associated expression with the provided claims. If the
+// expression evaluates to false, the error of the first failing term is
+// returned. Values which are not of the type required by their operator
+// result in ErrEnsureProductClaimValueTypeMismatch.
+func (e *Expr) Ensure(kpc *KopanoProductClaims) (err error) {
+	defer kpc.auditExpr(e.source, &err)
+	v, err := e.root.eval(kpc.quiet())
+	if err != nil {
+		return err
+	}
+	ok, err := v.asBool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return v.reasonOr(ErrEnsureProductClaimValueMismatch)
+	}
+	return nil
+}
+
+// EnsureExpr parses the provided expression and evaluates it with the
+// associated claims. See Expr for the syntax.
+func (kpc *KopanoProductClaims) EnsureExpr(expression string) (err error) {
+	defer kpc.auditExpr(expression, &err)
+	e, err := ParseExpr(expression)
+	if err != nil {
+		return err
+	}
+	return e.Ensure(kpc.quiet())
+}
+
+type exprKind int
+
+const (
+	exprKindUndefined exprKind = iota
+	exprKindBool
+	exprKindString
+	exprKindNumber
+	exprKindStringArray
+)
+
+// An exprValue is the result of evaluating a node. Undefined values and false
+// bool values carry the reason why they are not true.
+type exprValue struct {
+	kind exprKind
+
+	b bool
+	s string
+	n float64
+	a []string
+
+	// Integral numbers are also kept as int64 in i, so they are compared
+	// exactly even beyond the float64 precision.
+	i       int64
+	integer bool
+
+	reason error
+}
+
+func (v *exprValue) asBool() (bool, error) {
+	switch v.kind {
+	case exprKindBool:
+		return v.b, nil
+	case exprKindUndefined:
+		return false, nil
+	}
+	return false, ErrEnsureProductClaimValueTypeMismatch
+}
+
+// compareNumber returns -1, 0 or +1 depending on whether the associated number
+// is less than, equal to or greater than the provided one.
+func (v *exprValue) compareNumber(o *exprValue) int {
+	if v.integer && o.integer {
+		switch {
+		case v.i < o.i:
+			return -1
+		case v.i > o.i:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case v.n < o.n:
+		return -1
+	case v.n > o.n:
+		return 1
+	}
+	return 0
+}
+
+func exprNumber(v interface{}) exprValue {
+	f, _ := claimNumber(v)
+	i, integer := claimInt64(v)
+	return exprValue{kind: exprKindNumber, n: f, i: i, integer: integer}
+}
+
+func (v *exprValue) reasonOr(err error) error {
+	if v.reason != nil {
+		return v.reason
+	}
+	return err
+}
+
+func exprFalse(reason error) exprValue {
+	return exprValue{
+		kind:   exprKindBool,
+		reason: reason,
+	}
+}
+
+type exprNode interface {
+	eval(kpc *KopanoProductClaims) (exprValue, error)
+}
+
+type exprLiteral struct {
+	value exprValue
+}
+
+func (n *exprLiteral) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	return n.value, nil
+}
+
+type exprState struct {
+	trusted bool
+}
+
+func (n *exprState) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	var err error
+	if n.trusted {
+		err = kpc.EnsureTrusted()
+	} else {
+		err = kpc.EnsureOnline()
+	}
+	if err != nil {
+		return exprFalse(err), nil
+	}
+	return exprValue{kind: exprKindBool, b: true}, nil
+}
+
+type exprClaim struct {
+	product string
+	claim   string
+}
+
+func (n *exprClaim) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	if n.claim == "ok" {
+		if err := kpc.ensureOK(n.product); err != nil {
+			return exprFalse(err), nil
+		}
+		return exprValue{kind: exprKindBool, b: true}, nil
+	}
+
+	v, err := kpc.ensureValue(n.product, n.claim)
+	if err != nil {
+		return exprValue{kind: exprKindUndefined, reason: err}, nil
+	}
+
+	switch tv := v.(type) {
+	case bool:
+		if !tv {
+			return exprFalse(ErrEnsureProductClaimValueMismatch), nil
+		}
+		return exprValue{kind: exprKindBool, b: true}, nil
+	case string:
+		return exprValue{kind: exprKindString, s: tv}, nil
+	case float64, json.Number:
+		return exprNumber(tv), nil
+	case []interface{}:
+		a := make([]string, len(tv))
+		for i, iv := range tv {
+			s, ok := iv.(string)
+			if !ok {
+				return exprValue{}, newEnsureValueError(ErrEnsureProductClaimValueTypeMismatch, n.product, n.claim, "", "[]string", v)
+			}
+			a[i] = s
+		}
+		return exprValue{kind: exprKindStringArray, a: a}, nil
+	}
+	return exprValue{}, newEnsureValueError(ErrEnsureProductClaimValueTypeMismatch, n.product, n.claim, "", nil, v)
+}
+
+type exprNot struct {
+	operand exprNode
+}
+
+func (n *exprNot) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	v, err := n.operand.eval(kpc)
+	if err != nil {
+		return v, err
+	}
+	if v.kind == exprKindUndefined {
+		// A missing claim is not the same as a false one, so negating it
+		// must not grant access.
+		return exprFalse(v.reason), nil
+	}
+	b, err := v.asBool()
+	if err != nil {
+		return v, err
+	}
+	if b {
+		return exprFalse(ErrEnsureProductClaimValueMismatch), nil
+	}
+	return exprValue{kind: exprKindBool, b: true}, nil
+}
+
+type exprLogical struct {
+	or          bool
+	left, right exprNode
+}
+
+func (n *exprLogical) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	left, err := n.left.eval(kpc)
+	if err != nil {
+		return left, err
+	}
+	lb, err := left.asBool()
+	if err != nil {
+		return left, err
+	}
+	if lb == n.or {
+		// Short circuit, true for || and false for &&.
+		return left, nil
+	}
+
+	right, err := n.right.eval(kpc)
+	if err != nil {
+		return right, err
+	}
+	rb, err := right.asBool()
+	if err != nil {
+		return right, err
+	}
+	if !rb && n.or {
+		return exprFalse(left.reasonOr(right.reason)), nil
+	}
+	return right, nil
+}
+
+type exprCompare struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *exprCompare) eval(kpc *KopanoProductClaims) (exprValue, error) {
+	left, err := n.left.eval(kpc)
+	if err != nil {
+		return left, err
+	}
+	right, err := n.right.eval(kpc)
+	if err != nil {
+		return right, err
+	}
+	if left.kind == exprKindUndefined {
+		return exprFalse(left.reason), nil
+	}
+	if right.kind == exprKindUndefined {
+		return exprFalse(right.reason), nil
+	}
+
+	var result bool
+	switch n.op {
+	case "in":
+		if left.kind != exprKindString || right.kind != exprKindStringArray {
+			return exprValue{}, ErrEnsureProductClaimValueTypeMismatch
+		}
+		for _, s := range right.a {
+			if s == left.s {
+				result = true
+				break
+			}
+		}
+	case "==", "!=":
+		if left.kind != right.kind || left.kind == exprKindStringArray {
+			return exprValue{}, ErrEnsureProductClaimValueTypeMismatch
+		}
+		if left.kind == exprKindNumber {
+			result = left.compareNumber(&right) == 0
+		} else {
+			result = left.b == right.b && left.s == right.s
+		}
+		if n.op == "!=" {
+			result = !result
+		}
+	default:
+		if left.kind != exprKindNumber || right.kind != exprKindNumber {
+			return exprValue{}, ErrEnsureProductClaimValueTypeMismatch
+		}
+		c := left.compareNumber(&right)
+		switch n.op {
+		case ">":
+			result = c > 0
+		case ">=":
+			result = c >= 0
+		case "<":
+			result = c < 0
+		case "<=":
+			result = c <= 0
+		}
+	}
+
+	if !result {
+		return exprFalse(ErrEnsureProductClaimValueMismatch), nil
+	}
+	return exprValue{kind: exprKindBool, b: true}, nil
+}
+
+type exprTokenType int
+
+const (
+	exprTokenOperator exprTokenType = iota
+	exprTokenIdent
+	exprTokenString
+	exprTokenNumber
+)
+
+type exprToken struct {
+	t     exprTokenType
+	value string
+}
+
+type exprParser struct {
+	source string
+	tokens []exprToken
+	pos    int
+}
+
+var exprOperators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!", "(", ")"}
+
+func isExprIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '/' || r == '~'
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.source
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return ErrEnsureInvalidExpression
+			}
+			value, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return ErrEnsureInvalidExpression
+			}
+			p.tokens = append(p.tokens, exprToken{exprTokenString, value})
+			i = j + 1
+			continue
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for ; j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.'); j++ {
+			}
+			p.tokens = append(p.tokens, exprToken{exprTokenNumber, s[i:j]})
+			i = j
+			continue
+		}
+		if r, size := utf8.DecodeRuneInString(s[i:]); r == '_' || unicode.IsLetter(r) {
+			j := i + size
+			for j < len(s) {
+				r, size = utf8.DecodeRuneInString(s[j:])
+				if !isExprIdentRune(r) {
+					break
+				}
+				j += size
+			}
+			p.tokens = append(p.tokens, exprToken{exprTokenIdent, s[i:j]})
+			i = j
+			continue
+		}
+
+		found := false
+		for _, op := range exprOperators {
+			if strings.HasPrefix(s[i:], op) {
+				p.tokens = append(p.tokens, exprToken{exprTokenOperator, op})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrEnsureInvalidExpression
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *exprParser) acceptOperator(ops ...string) string {
+	t := p.peek()
+	if t == nil {
+		return ""
+	}
+	if t.t != exprTokenOperator && !(t.t == exprTokenIdent && t.value == "in") {
+		return ""
+	}
+	for _, op := range ops {
+		if t.value == op {
+			p.pos++
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator("||") != "" {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprLogical{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator("&&") != "" {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprLogical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.acceptOperator("!") != "" {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if op := p.acceptOperator("==", "!=", ">=", "<=", ">", "<", "in"); op != "" {
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &exprCompare{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, ErrEnsureInvalidExpression
+	}
+	p.pos++
+
+	switch t.t {
+	case exprTokenOperator:
+		if t.value != "(" {
+			return nil, ErrEnsureInvalidExpression
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.acceptOperator(")") == "" {
+			return nil, ErrEnsureInvalidExpression
+		}
+		return node, nil
+	case exprTokenString:
+		return &exprLiteral{exprValue{kind: exprKindString, s: t.value}}, nil
+	case exprTokenNumber:
+		if _, err := strconv.ParseFloat(t.value, 64); err != nil {
+			return nil, ErrEnsureInvalidExpression
+		}
+		return &exprLiteral{exprNumber(json.Number(t.value))}, nil
+	}
+
+	switch t.value {
+	case "true":
+		return &exprLiteral{exprValue{kind: exprKindBool, b: true}}, nil
+	case "false":
+		return &exprLiteral{exprFalse(nil)}, nil
+	case "online":
+		return &exprState{}, nil
+	case "trusted":
+		return &exprState{trusted: true}, nil
+	}
+
+	parts := strings.SplitN(t.value, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrEnsureInvalidExpression
+	}
+	return &exprClaim{product: parts[0], claim: parts[1]}, nil
+}
@@ -0,0 +1,89 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	api "stash.kopano.io/kgol/kustomer/server/api-v1"
+)
+
+func newTestKopanoProductClaims() *KopanoProductClaims {
+	return &KopanoProductClaims{
+		response: &api.ClaimsKopanoProductsResponse{
+			Trusted: true,
+			Offline: false,
+			Products: map[string]*api.ClaimsKopanoProductsResponseProduct{
+				"groupware": {
+					OK: true,
+					Claims: map[string]interface{}{
+						"max_users": float64(100),
+						"edition":   "enterprise",
+						"features":  []interface{}{"archiver", "webapp"},
+						"hosted":    false,
+					},
+				},
+				"meet": {
+					OK:     false,
+					Claims: map[string]interface{}{},
+				},
+			},
+		},
+	}
+}
+
+func TestEnsureExpr(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+
+	for expression, expected := range map[string]error{
+		`online && trusted && groupware.ok && groupware.max_users >= 50 && "archiver" in groupware.features`: nil,
+		`groupware.edition == "enterprise" && !groupware.hosted`:                                             nil,
+		`meet.ok || groupware.max_users < 200`:                                                               nil,
+		`(groupware.max_users > 100 || groupware.hosted) && online`:                                          ErrEnsureProductClaimValueMismatch,
+		`meet.ok`:                      ErrEnsureProductNotLicensed,
+		`unknown.ok`:                   ErrEnsureProductNotFound,
+		`groupware.missing == 1`:       ErrEnsureProductClaimNotFound,
+		`!groupware.missing`:           ErrEnsureProductClaimNotFound,
+		`!(groupware.missing)`:         ErrEnsureProductClaimNotFound,
+		`groupware.édition == "x"`:     ErrEnsureProductClaimNotFound,
+		`groupware.edition == "é"`:     ErrEnsureProductClaimValueMismatch,
+		`"kdav" in groupware.features`: ErrEnsureProductClaimValueMismatch,
+		`groupware.edition > 1`:        ErrEnsureProductClaimValueTypeMismatch,
+		`groupware.max_users`:          ErrEnsureProductClaimValueTypeMismatch,
+		`groupware.max_users >=`:       ErrEnsureInvalidExpression,
+		`(online`:                      ErrEnsureInvalidExpression,
+		`groupware`:                    ErrEnsureInvalidExpression,
+		`groupware.max_users € 1`:      ErrEnsureInvalidExpression,
+		`"unterminated`:                ErrEnsureInvalidExpression,
+	} {
+		err := kpc.EnsureExpr(expression)
+		if !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v, got %v", expression, expected, err)
+		}
+	}
+}
+
+func TestEnsureExprInt64Precision(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	// 2^53+1 is not representable as float64 and would be rounded to 2^53.
+	kpc.response.Products["groupware"].Claims["max_users"] = json.Number("9007199254740993")
+
+	for expression, expected := range map[string]error{
+		`groupware.max_users >= 9007199254740993`: nil,
+		`groupware.max_users == 9007199254740993`: nil,
+		`groupware.max_users > 9007199254740992`:  nil,
+		`groupware.max_users == 9007199254740992`: ErrEnsureProductClaimValueMismatch,
+		`groupware.max_users < 9007199254740993`:  ErrEnsureProductClaimValueMismatch,
+		`groupware.max_users > 1.5`:               nil,
+	} {
+		err := kpc.EnsureExpr(expression)
+		if !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v, got %v", expression, expected, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// FeatureGates map named application features to license checks. All checks
+// are evaluated once per claims update, so Enabled is a cheap lookup which can
+// be called on every request.
+type FeatureGates struct {
+	mutex sync.Mutex
+
+	checks   map[string]func(*KopanoProductClaims) error
+	handlers []func(feature string, enabled bool)
+	last     *KopanoProductClaims
+
+	// started and stored are the sequence numbers of the most recently started
+	// and the most recently stored evaluation. Checks run without the mutex,
+	// so a slow evaluation must not overwrite the result of a newer one.
+	started uint64
+	stored  uint64
+
+	// enabled holds a map[string]bool which is replaced, never modified, on
+	// every evaluation.
+	enabled atomic.Value
+}
+
+// NewFeatureGates creates a new empty FeatureGates registry. Use Watch to keep
+// it up to date with the claims of a Kustomer instance.
+func NewFeatureGates() *FeatureGates {
+	g := &FeatureGates{
+		checks: make(map[string]func(*KopanoProductClaims) error),
+	}
+	g.enabled.Store(make(map[string]bool))
+	return g
+}
+
+// Register registers the provided feature with the provided check. The
+// feature is enabled when the check returns no error. An already registered
+// check for the same feature is replaced.
+func (g *FeatureGates) Register(feature string, check func(*KopanoProductClaims) error) {
+	g.mutex.Lock()
+	g.checks[feature] = check
+	kpc := g.last
+	g.mutex.Unlock()
+
+	if kpc != nil {
+		g.Evaluate(kpc)
+	}
+}
+
+// RegisterExpr registers the provided feature with the provided policy
+// expression as check. See ParseExpr for the expression syntax.
+func (g *FeatureGates) RegisterExpr(feature, expression string) error {
+	e, err := ParseExpr(expression)
+	if err != nil {
+		return err
+	}
+	g.Register(feature, e.Ensure)
+	return nil
+}
+
+// OnChange adds the provided handler, which is called for every feature which
+// got enabled or disabled by an evaluation.
+func (g *FeatureGates) OnChange(handler func(feature string, enabled bool)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.handlers = append(g.handlers, handler)
+}
+
+// Enabled returns true if the provided feature is registered and its check
+// passed with the most recently evaluated claims. Enabled does not allocate.
+func (g *FeatureGates) Enabled(feature string) bool {
+	return g.enabled.Load().(map[string]bool)[feature]
+}
+
+// Evaluate evaluates all registered features with the provided claims and
+// calls the change handlers for all features which changed. Checks are called
+// without holding any lock, so they can use the associated registry.
+func (g *FeatureGates) Evaluate(kpc *KopanoProductClaims) {
+	g.mutex.Lock()
+	g.started++
+	seq := g.started
+	g.last = kpc
+	checks := make(map[string]func(*KopanoProductClaims) error, len(g.checks))
+	for feature, check := range g.checks {
+		checks[feature] = check
+	}
+	g.mutex.Unlock()
+
+	enabled := make(map[string]bool, len(checks))
+	for feature, check := range checks {
+		enabled[feature] = check(kpc) == nil
+	}
+
+	g.store(seq, enabled)
+}
+
+// Reset disables all features until the next evaluation, for example when the
+// claims they were evaluated with are no longer valid.
+func (g *FeatureGates) Reset() {
+	g.mutex.Lock()
+	g.started++
+	seq := g.started
+	g.last = nil
+	g.mutex.Unlock()
+
+	g.store(seq, make(map[string]bool))
+}
+
+// store replaces the enabled features with the result of the evaluation with
+// the provided sequence number, unless a newer result has been stored already,
+// and calls the change handlers.
+func (g *FeatureGates) store(seq uint64, enabled map[string]bool) {
+	g.mutex.Lock()
+	if seq < g.stored {
+		g.mutex.Unlock()
+		return
+	}
+	g.stored = seq
+	previous := g.enabled.Load().(map[string]bool)
+	g.enabled.Store(enabled)
+	handlers := g.handlers
+	g.mutex.Unlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+	for feature, flag := range enabled {
+		if previous[feature] != flag {
+			for _, handler := range handlers {
+				handler(feature, flag)
+			}
+		}
+	}
+	for feature, flag := range previous {
+		if _, ok := enabled[feature]; !ok && flag {
+			for _, handler := range handlers {
+				handler(feature, false)
+			}
+		}
+	}
+}
+
+// Watch evaluates all registered features with the active claims of the
+// provided Kustomer instance, and again whenever the claims have been
+// updated. Calling this function blocks until the provided context is done or
+// until the provided instance is uninitialized.
+func (g *FeatureGates) Watch(ctx context.Context, k *Kustomer) error {
+	return k.watchUpdates(ctx, g.Evaluate)
+}
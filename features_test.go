@@ -0,0 +1,85 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestFeatureGates(t *testing.T) {
+	g := NewFeatureGates()
+	if err := g.RegisterExpr("archiving", `groupware.ok && "archiver" in groupware.features`); err != nil {
+		t.Fatal(err)
+	}
+	g.Register("meetings", func(kpc *KopanoProductClaims) error {
+		return kpc.EnsureOK("meet")
+	})
+	if err := g.RegisterExpr("broken", `groupware.max_users >`); err == nil {
+		t.Errorf("expected error for invalid expression")
+	}
+
+	changes := make(map[string]bool)
+	g.OnChange(func(feature string, enabled bool) {
+		changes[feature] = enabled
+	})
+
+	if g.Enabled("archiving") {
+		t.Errorf("expected archiving to be disabled before evaluation")
+	}
+
+	kpc := newTestKopanoProductClaims()
+	g.Evaluate(kpc)
+	if !g.Enabled("archiving") || g.Enabled("meetings") || g.Enabled("unknown") {
+		t.Errorf("unexpected feature state")
+	}
+	if len(changes) != 1 || !changes["archiving"] {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+
+	// Registering after evaluation evaluates with the last claims.
+	g.Register("users", func(kpc *KopanoProductClaims) error {
+		return kpc.EnsureInt64WithOperator("groupware", "max_users", 50, OperatorGreaterThan)
+	})
+	if !g.Enabled("users") {
+		t.Errorf("expected users to be enabled")
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		g.Enabled("archiving")
+	}); allocs != 0 {
+		t.Errorf("unexpected allocations: %v", allocs)
+	}
+}
+
+func TestFeatureGatesRegisterFromCheck(t *testing.T) {
+	g := NewFeatureGates()
+	var registered int32
+	g.Register("outer", func(kpc *KopanoProductClaims) error {
+		if atomic.CompareAndSwapInt32(&registered, 0, 1) {
+			g.Register("inner", func(kpc *KopanoProductClaims) error {
+				return kpc.EnsureOK("groupware")
+			})
+		}
+		return nil
+	})
+
+	g.Evaluate(newTestKopanoProductClaims())
+	if !g.Enabled("outer") || !g.Enabled("inner") {
+		t.Errorf("expected features registered by checks to be evaluated")
+	}
+
+	var disabled []string
+	g.OnChange(func(feature string, enabled bool) {
+		if !enabled {
+			disabled = append(disabled, feature)
+		}
+	})
+	g.Reset()
+	if g.Enabled("outer") || g.Enabled("inner") || len(disabled) != 2 {
+		t.Errorf("expected reset to disable all features, got %v", disabled)
+	}
+}
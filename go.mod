@@ -1,9 +1,16 @@
 module stash.kopano.io/kc/libkustomer
 
-go 1.14
+go 1.18
 
 require (
 	github.com/longsleep/sse v1.4.0
 	github.com/mattn/go-pointer v0.0.0-20190911064623-a0a44394634f
+	gopkg.in/yaml.v2 v2.2.2
 	stash.kopano.io/kgol/kustomer v0.4.0
 )
+
+require (
+	github.com/google/uuid v1.1.1 // indirect
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073 // indirect
+	gopkg.in/square/go-jose.v2 v2.4.1 // indirect
+)
@@ -0,0 +1,375 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+// Package semver implements parsing and comparison of semantic versions and
+// version constraint expressions.
+package semver
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the parse functions of this package.
+var (
+	ErrInvalidVersion    = errors.New("invalid version")
+	ErrInvalidConstraint = errors.New("invalid version constraint")
+)
+
+// A Version is a semantic version.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// partial is a version with possibly missing or wildcard components. Only
+// the first n components are set.
+type partial struct {
+	Version
+	n        int
+	wildcard bool
+}
+
+// Parse parses the provided version string. A leading v is accepted and
+// missing minor and patch components default to zero.
+func Parse(s string) (*Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	if p.n == 0 || p.wildcard {
+		return nil, ErrInvalidVersion
+	}
+	return &p.Version, nil
+}
+
+func parsePartial(s string) (*partial, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, ErrInvalidVersion
+	}
+
+	p := &partial{}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		p.Build = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		p.Prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range p.Prerelease {
+			if id == "" {
+				return nil, ErrInvalidVersion
+			}
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, ErrInvalidVersion
+	}
+	components := []*uint64{&p.Major, &p.Minor, &p.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			p.wildcard = true
+			continue
+		}
+		if p.wildcard {
+			return nil, ErrInvalidVersion
+		}
+		value, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidVersion
+		}
+		*components[i] = value
+		p.n++
+	}
+	if p.n < 3 && p.Prerelease != nil {
+		return nil, ErrInvalidVersion
+	}
+
+	return p, nil
+}
+
+// String returns the associated version in its canonical form.
+func (v *Version) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." + strconv.FormatUint(v.Minor, 10) + "." + strconv.FormatUint(v.Patch, 10)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on if the associated version is lower,
+// equal or higher than the provided version. Build metadata is ignored.
+func (v *Version) Compare(o *Version) int {
+	for _, c := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] < c[1] {
+			return -1
+		}
+		if c[0] > c[1] {
+			return 1
+		}
+	}
+
+	// A version without prerelease has higher precedence.
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.Prerelease) < len(o.Prerelease):
+		return -1
+	case len(v.Prerelease) > len(o.Prerelease):
+		return 1
+	}
+	return 0
+}
+
+func comparePrereleaseIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		// Numeric identifiers have lower precedence.
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// lower returns the lowest version matched by the associated partial version.
+func (p *partial) lower() *Version {
+	v := p.Version
+	return &v
+}
+
+// upper returns the lowest version above all versions matched by the
+// associated partial version. It returns nil if there is no upper bound.
+func (p *partial) upper() *Version {
+	switch p.n {
+	case 1:
+		return &Version{Major: p.Major + 1}
+	case 2:
+		return &Version{Major: p.Major, Minor: p.Minor + 1}
+	case 3:
+		return &Version{Major: p.Major, Minor: p.Minor, Patch: p.Patch + 1}
+	}
+	return nil
+}
+
+type comparator func(v *Version) bool
+
+func matchAll(v *Version) bool {
+	return true
+}
+
+func matchNone(v *Version) bool {
+	return false
+}
+
+func atLeast(lower *Version) comparator {
+	return func(v *Version) bool {
+		return v.Compare(lower) >= 0
+	}
+}
+
+func below(upper *Version) comparator {
+	if upper == nil {
+		return matchAll
+	}
+	return func(v *Version) bool {
+		return v.Compare(upper) < 0
+	}
+}
+
+func between(lower, upper *Version) comparator {
+	if upper == nil {
+		return atLeast(lower)
+	}
+	return func(v *Version) bool {
+		return v.Compare(lower) >= 0 && v.Compare(upper) < 0
+	}
+}
+
+// A Constraint is a parsed version constraint expression.
+type Constraint struct {
+	source string
+	sets   [][]comparator
+}
+
+var constraintOperators = []string{">=", "<=", "!=", "==", ">", "<", "=", "^", "~"}
+
+// ParseConstraint parses the provided version constraint expression. An
+// expression is a list of alternatives separated by ||. Each alternative is a
+// list of comparisons separated by spaces or commas, which all must match.
+// Supported are the operators =, !=, >, >=, <, <=, the caret (^1.2.3 matches
+// >=1.2.3 <2.0.0), the tilde (~1.2.3 matches >=1.2.3 <1.3.0), hyphen ranges
+// (1.2 - 1.4 matches >=1.2.0 <1.5.0) and wildcards (11.x or 11 matches
+// >=11.0.0 <12.0.0).
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{
+		source: s,
+	}
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		var set []comparator
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if i+2 < len(fields) && fields[i+1] == "-" {
+				// Hyphen range.
+				lower, err := parsePartial(field)
+				if err != nil {
+					return nil, ErrInvalidConstraint
+				}
+				upper, err := parsePartial(fields[i+2])
+				if err != nil {
+					return nil, ErrInvalidConstraint
+				}
+				set = append(set, atLeast(lower.lower()), newComparator("<=", upper))
+				i += 2
+				continue
+			}
+
+			op := ""
+			for _, o := range constraintOperators {
+				if strings.HasPrefix(field, o) {
+					op = o
+					break
+				}
+			}
+			value := field[len(op):]
+			if value == "" && op != "" && i+1 < len(fields) {
+				// Operator separated from version by a space.
+				i++
+				value = fields[i]
+			}
+			p, err := parsePartial(value)
+			if err != nil {
+				return nil, ErrInvalidConstraint
+			}
+			set = append(set, newComparator(op, p))
+		}
+		if len(set) == 0 {
+			return nil, ErrInvalidConstraint
+		}
+		c.sets = append(c.sets, set)
+	}
+
+	return c, nil
+}
+
+func newComparator(op string, p *partial) comparator { //nolint:gocyclo
+	exact := p.n == 3
+	switch op {
+	case "", "=", "==":
+		if exact {
+			return func(v *Version) bool {
+				return v.Compare(&p.Version) == 0
+			}
+		}
+		return between(p.lower(), p.upper())
+	case "!=":
+		match := newComparator("=", p)
+		return func(v *Version) bool {
+			return !match(v)
+		}
+	case ">":
+		if exact {
+			return func(v *Version) bool {
+				return v.Compare(&p.Version) > 0
+			}
+		}
+		if p.n == 0 {
+			return matchNone
+		}
+		return atLeast(p.upper())
+	case ">=":
+		return atLeast(p.lower())
+	case "<":
+		if p.n == 0 {
+			return matchNone
+		}
+		return below(p.lower())
+	case "<=":
+		if exact {
+			return func(v *Version) bool {
+				return v.Compare(&p.Version) <= 0
+			}
+		}
+		return below(p.upper())
+	case "^":
+		if p.n == 0 {
+			return matchAll
+		}
+		var upper *Version
+		switch {
+		case p.Major > 0 || p.n == 1:
+			upper = &Version{Major: p.Major + 1}
+		case p.Minor > 0 || p.n == 2:
+			upper = &Version{Minor: p.Minor + 1}
+		default:
+			upper = &Version{Patch: p.Patch + 1}
+		}
+		return between(p.lower(), upper)
+	case "~":
+		switch p.n {
+		case 0:
+			return matchAll
+		case 1:
+			return between(p.lower(), &Version{Major: p.Major + 1})
+		}
+		return between(p.lower(), &Version{Major: p.Major, Minor: p.Minor + 1})
+	}
+	return matchNone
+}
+
+// Check returns true if the provided version matches the associated
+// constraint.
+func (c *Constraint) Check(v *Version) bool {
+	for _, set := range c.sets {
+		matched := true
+		for _, match := range set {
+			if !match(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the source of the associated constraint.
+func (c *Constraint) String() string {
+	return c.source
+}
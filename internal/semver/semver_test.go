@@ -0,0 +1,77 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package semver
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.10.0", "v11",
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, err := Parse(ordered[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+
+	for _, s := range []string{"", "1.2.3.4", "1.x.3", "a.b.c", "1.2.3-"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	for constraint, versions := range map[string]map[string]bool{
+		"<=11.x":          {"11.9.9": true, "12.0.0": false, "10.0.0": true},
+		"^1.2.3":          {"1.2.3": true, "1.9.0": true, "2.0.0": false, "1.2.2": false},
+		"^0.2.3":          {"0.2.9": true, "0.3.0": false},
+		"~1.2.3":          {"1.2.9": true, "1.3.0": false},
+		"~1":              {"1.9.0": true, "2.0.0": false},
+		">=1.2 <2":        {"1.2.0": true, "1.9.9": true, "2.0.0": false},
+		">= 1.2, < 1.4":   {"1.3.0": true, "1.4.0": false},
+		"1.2 - 1.4":       {"1.4.9": true, "1.5.0": false, "1.1.9": false},
+		"1.x || >=3.0.0":  {"1.5.0": true, "2.0.0": false, "3.1.0": true},
+		"!=1.2.3":         {"1.2.3": false, "1.2.4": true},
+		"*":               {"0.0.1": true},
+		">11":             {"11.9.0": false, "12.0.0": true},
+		"11":              {"11.2.0": true, "12.0.0": false},
+		"=1.0.0-beta.2":   {"1.0.0-beta.2": true, "1.0.0": false},
+		"<1.0.0 || >=2.0": {"0.9.0": true, "1.5.0": false, "2.0.0": true},
+	} {
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			t.Errorf("%s: %v", constraint, err)
+			continue
+		}
+		for version, expected := range versions {
+			v, err := Parse(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Check(v) != expected {
+				t.Errorf("%s: expected %s to be %v", constraint, version, expected)
+			}
+		}
+	}
+
+	for _, s := range []string{"", ">=", "1.2.x.4", "~a", "1.0 ||"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
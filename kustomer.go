@@ -43,13 +43,29 @@ type Kustomer struct {
 	debug       bool
 	autoRefresh bool
 
-	apiPath string
+	apiPath       string
+	fetchTimeout  time.Duration
+	retryInterval time.Duration
 
 	updated                    chan struct{}
 	currentKopanoProductClaims *api.ClaimsKopanoProductsResponse
+	generation                 uint64
+
+	auditHook AuditHook
+
+	softEnforcement    *softEnforcement
+	softFailureHandler func(*SoftFailure)
+
+	expiryThresholds []time.Duration
+	expiryHandlers   []func(*ExpiryApproaching)
+
+	clock Clock
 
 	fetching      chan struct{}
 	currentClaims *api.ClaimsResponse
+
+	schemas                map[string]*ProductSchema
+	schemaViolationHandler func([]*SchemaViolation)
 }
 
 // New creates a new Kustomer instance using the provided configuration.
@@ -66,6 +82,16 @@ func New(config *Config) (*Kustomer, error) {
 		debug:       config.Debug,
 		autoRefresh: config.AutoRefresh,
 
+		apiPath:       config.APIPath,
+		fetchTimeout:  config.FetchTimeout,
+		retryInterval: config.RetryInterval,
+
+		expiryThresholds: config.ExpiryThresholds,
+
+		clock: config.Clock,
+
+		auditHook: config.AuditHook,
+
 		updated: make(chan struct{}),
 		currentKopanoProductClaims: &api.ClaimsKopanoProductsResponse{
 			Trusted:  false,
@@ -78,14 +104,31 @@ func New(config *Config) (*Kustomer, error) {
 	k.httpClient = &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, proto, addr string) (conn net.Conn, err error) {
-				if !k.initialized {
+				k.mutex.RLock()
+				initialized := k.initialized
+				apiPath := k.apiPath
+				k.mutex.RUnlock()
+				if !initialized {
 					return nil, fmt.Errorf("cannot dial to API: %w", ErrStatusNotInitialized)
 				}
-				return dialer.DialContext(ctx, "unix", k.apiPath)
+				return dialer.DialContext(ctx, "unix", apiPath)
 			},
 		},
 	}
 
+	if k.fetchTimeout <= 0 {
+		k.fetchTimeout = DefaultFetchTimeout
+	}
+	if k.retryInterval <= 0 {
+		k.retryInterval = DefaultRetryInterval
+	}
+	if k.clock == nil {
+		k.clock = SystemClock
+	}
+	if k.expiryThresholds == nil {
+		k.expiryThresholds = DefaultExpiryThresholds
+	}
+
 	k.requestGenerator = newRequestGenerator(config.ProductUserAgent)
 
 	return k, nil
@@ -126,7 +169,11 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 
 	apiPath := DefaultAPIPath
 	trusted := true
-	if a := os.Getenv("KUSTOMER_API_PATH"); a != "" {
+	a := os.Getenv("KUSTOMER_API_PATH")
+	if a == "" {
+		a = k.apiPath
+	}
+	if a != "" {
 		absPath, absErr := filepath.Abs(a)
 		if absErr != nil {
 			return absErr
@@ -145,6 +192,7 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 		debug := k.debug
 		logger := k.logger
 		autoRefresh := k.autoRefresh
+		retryInterval := k.retryInterval
 		if !autoRefresh || k.ready != ready || !k.initialized {
 			k.mutex.RUnlock()
 			return
@@ -193,7 +241,7 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 					select {
 					case <-initializeCtx.Done():
 						return
-					case <-time.After(5 * time.Second):
+					case <-k.clock.After(retryInterval):
 						first = true // Ensures to trigger after successful reconnect.
 						// breaks
 						break retry
@@ -233,11 +281,16 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 
 	go func() {
 		var first = true
+		k.mutex.RLock()
+		expiry := newExpiryTracker(k.expiryThresholds)
+		k.mutex.RUnlock()
 		for {
 			k.mutex.Lock()
 			debug := k.debug
 			logger := k.logger
 			autoRefresh := k.autoRefresh
+			fetchTimeout := k.fetchTimeout
+			retryInterval := k.retryInterval
 			if k.ready != ready || !k.initialized {
 				k.mutex.Unlock()
 				return
@@ -257,7 +310,7 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 				}
 			}
 
-			timeoutContext, timeoutContextCancel := context.WithTimeout(k.ctx, 60*time.Second)
+			timeoutContext, timeoutContextCancel := WithClockTimeout(k.ctx, k.clock, fetchTimeout)
 			kopanoProductClaims, err := k.fetchClaimsKopanoProducts(timeoutContext, productName)
 			timeoutContextCancel()
 			if err != nil {
@@ -269,7 +322,7 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 				select {
 				case <-initializeCtx.Done():
 					return
-				case <-time.After(5 * time.Second):
+				case <-k.clock.After(retryInterval):
 					// breaks
 				}
 				continue
@@ -278,11 +331,24 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 			if kopanoProductClaims != nil {
 				k.mutex.Lock()
 				k.currentKopanoProductClaims = kopanoProductClaims
+				k.generation++
 				updated := k.updated
 				k.updated = make(chan struct{})
 				close(updated)
 				k.mutex.Unlock()
+
+				k.reportSchemaViolations(&KopanoProductClaims{
+					response: kopanoProductClaims,
+				})
+			}
+
+			k.mutex.RLock()
+			current := &KopanoProductClaims{
+				response:       k.currentKopanoProductClaims,
+				allowUntrusted: true,
 			}
+			k.mutex.RUnlock()
+			next, atExpiry := k.checkExpiry(expiry, current, k.clock.Now())
 
 			if first {
 				// If this is the first run, signal that operation is ready.
@@ -293,12 +359,36 @@ func (k *Kustomer) Initialize(ctx context.Context, productName *string) error {
 				// No auto refresh, exit here directly.
 				return
 			}
-			// Wait for signal to run again or to exit.
-			select {
-			case <-initializeCtx.Done():
-				return
-			case <-trigger:
-				// breaks
+			// Wait for signal to run again or to exit. Approaching expiries are
+			// checked in between, and claims are fetched again right at expiry.
+		wait:
+			for {
+				var expiryCh <-chan time.Time
+				var expiryTimer Timer
+				if !next.IsZero() {
+					expiryTimer = k.clock.NewTimer(next.Sub(k.clock.Now()))
+					expiryCh = expiryTimer.C()
+				}
+				select {
+				case <-initializeCtx.Done():
+					if expiryTimer != nil {
+						expiryTimer.Stop()
+					}
+					return
+				case <-trigger:
+					if expiryTimer != nil {
+						expiryTimer.Stop()
+					}
+					break wait
+				case <-expiryCh:
+					if atExpiry {
+						if debug {
+							logger.Printf("libkustomer claims expired, fetching again\n")
+						}
+						break wait
+					}
+					next, atExpiry = k.checkExpiry(expiry, current, k.clock.Now())
+				}
 			}
 		}
 	}()
@@ -377,6 +467,42 @@ func (k *Kustomer) NotifyWhenUpdated(ctx context.Context, eventCh chan<- bool) e
 	return err
 }
 
+// watchUpdates calls the provided function with the active claims of the
+// associated instance once the instance is ready and then again whenever the
+// claims have been updated. Calling this function blocks until the provided
+// context is done or until the associated instance is uninitialized.
+func (k *Kustomer) watchUpdates(ctx context.Context, fn func(*KopanoProductClaims)) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	eventCh := make(chan bool)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- k.NotifyWhenUpdated(watchCtx, eventCh)
+	}()
+
+	if err := k.WaitUntilReady(watchCtx); err != nil {
+		cancel()
+		for {
+			select {
+			case <-eventCh:
+			case <-errCh:
+				return err
+			}
+		}
+	}
+	fn(k.CurrentKopanoProductClaims(watchCtx))
+
+	for {
+		select {
+		case <-eventCh:
+			fn(k.CurrentKopanoProductClaims(watchCtx))
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
 func (k *Kustomer) fetchClaimsKopanoProducts(ctx context.Context, productName *string) (*api.ClaimsKopanoProductsResponse, error) {
 	uri := url.URL{
 		Scheme: "http",
@@ -407,7 +533,10 @@ func (k *Kustomer) fetchClaimsKopanoProducts(ctx context.Context, productName *s
 	}
 
 	kpc := &api.ClaimsKopanoProductsResponse{}
-	err = json.NewDecoder(response.Body).Decode(kpc)
+	decoder := json.NewDecoder(response.Body)
+	// Decode numbers as json.Number, so integer claims are kept exactly.
+	decoder.UseNumber()
+	err = decoder.Decode(kpc)
 	if err != nil {
 		return nil, fmt.Errorf("API response parse error: %w", err)
 	}
@@ -439,7 +568,10 @@ func (k *Kustomer) fetchClaims(ctx context.Context) (*api.ClaimsResponse, error)
 	}
 
 	cr := &api.ClaimsResponse{}
-	err = json.NewDecoder(response.Body).Decode(cr)
+	decoder := json.NewDecoder(response.Body)
+	// Decode numbers as json.Number, so integer claims are kept exactly.
+	decoder.UseNumber()
+	err = decoder.Decode(cr)
 	if err != nil {
 		return nil, fmt.Errorf("API response parse error: %w", err)
 	}
@@ -453,12 +585,31 @@ func (k *Kustomer) fetchClaims(ctx context.Context) (*api.ClaimsResponse, error)
 func (k *Kustomer) CurrentKopanoProductClaims(ctx context.Context) *KopanoProductClaims {
 	k.mutex.RLock()
 	kpc := k.currentKopanoProductClaims
+	generation := k.generation
+	auditHook := k.auditHook
+	softEnforcement := k.softEnforcement
+	clock := k.clock
 	k.mutex.RUnlock()
 	return &KopanoProductClaims{
 		response: kpc,
+
+		generation:      generation,
+		auditHook:       auditHook,
+		softEnforcement: softEnforcement,
+		clock:           clock,
 	}
 }
 
+// SetAuditHook sets the provided hook to be called with every license
+// decision made with the claims returned by CurrentKopanoProductClaims. Claims
+// returned before the change are not affected. Pass nil to disable auditing.
+func (k *Kustomer) SetAuditHook(hook AuditHook) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.auditHook = hook
+}
+
 // CurrentClaims returns the active claim set of the associated instance. This
 // function blocks until a value is available or until the provided context
 // is done. The fetched claims are cached, so no subsequent requests will
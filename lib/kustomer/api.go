@@ -7,10 +7,12 @@ package main //nolint
 
 /*
 #define KUSTOMER_API 1
-#define KUSTOMER_API_MINOR 0
+#define KUSTOMER_API_MINOR 1
 
 #define KUSTOMER_VERSION (KUSTOMER_API * 10000 + KUSTOMER_API_MINOR * 100)
 
+#include <stdlib.h>
+
 #include "kustomer_callbacks.h"
 
 // Keep enum in sync with operatorCodeArray.
@@ -19,6 +21,22 @@ enum {
 	KUSTOMER_OPERATOR_GE,
 	KUSTOMER_OPERATOR_LT,
 	KUSTOMER_OPERATOR_LE,
+	KUSTOMER_OPERATOR_IEQ,
+	KUSTOMER_OPERATOR_PREFIX,
+	KUSTOMER_OPERATOR_SUFFIX,
+	KUSTOMER_OPERATOR_GLOB,
+	KUSTOMER_OPERATOR_REGEX,
+	KUSTOMER_OPERATOR_EQ,
+	KUSTOMER_OPERATOR_NE,
+};
+
+// Keep enum in sync with stringArrayMatchArray.
+enum {
+	KUSTOMER_STRINGARRAY_ALL = 1,
+	KUSTOMER_STRINGARRAY_ANY,
+	KUSTOMER_STRINGARRAY_NONE,
+	KUSTOMER_STRINGARRAY_EQUALS,
+	KUSTOMER_STRINGARRAY_SUBSET,
 };
 */
 import "C" //nolint
@@ -94,6 +112,16 @@ func kustomer_set_productuseragent(productUserAgentCString *C.char) C.ulonglong
 	return kustomer.StatusSuccess
 }
 
+//export kustomer_dump_config
+func kustomer_dump_config() (C.ulonglong, *C.char) {
+	b, err := json.Marshal(libkustomer.DumpConfig())
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
 //export kustomer_initialize
 func kustomer_initialize(productNameCString *C.char) C.ulonglong {
 	var productName *string
@@ -154,6 +182,22 @@ func kustomer_unset_notify_when_updated() C.ulonglong {
 	return kustomer.StatusSuccess
 }
 
+//export kustomer_set_expiry_approaching_handler
+func kustomer_set_expiry_approaching_handler(expiryCb C.kustomer_cb_func_expiry) C.ulonglong {
+	if expiryCb == nil {
+		libkustomer.SetExpiryApproachingHandler(nil)
+		return kustomer.StatusSuccess
+	}
+
+	libkustomer.SetExpiryApproachingHandler(func(event *kustomer.ExpiryApproaching) {
+		productCString := C.CString(event.Product)
+		defer C.free(unsafe.Pointer(productCString))
+		C.bridge_kustomer_expiry_cb_func_approaching(expiryCb, productCString,
+			C.longlong(event.Expiry.Unix()), C.longlong(event.Remaining/time.Second), C.longlong(event.Threshold/time.Second))
+	})
+	return kustomer.StatusSuccess
+}
+
 //export kustomer_dump_claims
 func kustomer_dump_claims() (C.ulonglong, *C.char) {
 	claims, err := libkustomer.CurrentClaims()
@@ -169,12 +213,180 @@ func kustomer_dump_claims() (C.ulonglong, *C.char) {
 	return kustomer.StatusSuccess, C.CString(string(b))
 }
 
+func currentLicense(licenseIDCString *C.char) (*kustomer.License, error) {
+	claims, err := libkustomer.CurrentClaims()
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.License(C.GoString(licenseIDCString))
+}
+
+//export kustomer_claims_licenses_json
+func kustomer_claims_licenses_json() (C.ulonglong, *C.char) {
+	claims, err := libkustomer.CurrentClaims()
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	licenses, err := claims.Licenses()
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	b, err := json.Marshal(licenses)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_claims_license_claim_json
+func kustomer_claims_license_claim_json(licenseIDCString, claimCString *C.char) (C.ulonglong, *C.char) {
+	license, err := currentLicense(licenseIDCString)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	value, ok := license.Claim(C.GoString(claimCString))
+	if !ok {
+		return asKnownErrorOrUnknown(kustomer.ErrStatusClaimNotFound), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_claims_license_issued_at
+func kustomer_claims_license_issued_at(licenseIDCString *C.char) (C.ulonglong, C.longlong) {
+	license, err := currentLicense(licenseIDCString)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), 0
+	}
+	if license.IssuedAt.IsZero() {
+		return asKnownErrorOrUnknown(kustomer.ErrStatusClaimNotFound), 0
+	}
+
+	return kustomer.StatusSuccess, C.longlong(license.IssuedAt.Unix())
+}
+
+//export kustomer_claims_license_expiry
+func kustomer_claims_license_expiry(licenseIDCString *C.char) (C.ulonglong, C.longlong) {
+	license, err := currentLicense(licenseIDCString)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), 0
+	}
+	if license.Expiry.IsZero() {
+		return asKnownErrorOrUnknown(kustomer.ErrStatusClaimNotFound), 0
+	}
+
+	return kustomer.StatusSuccess, C.longlong(license.Expiry.Unix())
+}
+
+//export kustomer_set_audit_file
+func kustomer_set_audit_file(pathCString *C.char, maxSize C.longlong, maxBackups C.int) C.ulonglong {
+	var path string
+	if pathCString != nil {
+		path = C.GoString(pathCString)
+	}
+
+	err := libkustomer.SetAuditFile(path, int64(maxSize), int(maxBackups))
+	if err != nil {
+		return asKnownErrorOrUnknown(err)
+	}
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_set_soft_enforcement
+func kustomer_set_soft_enforcement(productNameCString *C.char, until C.longlong) C.ulonglong {
+	var untilTime time.Time
+	if until > 0 {
+		untilTime = time.Unix(int64(until), 0)
+	}
+
+	err := libkustomer.SetSoftEnforcement(C.GoString(productNameCString), untilTime)
+	if err != nil {
+		return asKnownErrorOrUnknown(err)
+	}
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_clear_soft_enforcement
+func kustomer_clear_soft_enforcement(productNameCString *C.char) C.ulonglong {
+	err := libkustomer.ClearSoftEnforcement(C.GoString(productNameCString))
+	if err != nil {
+		return asKnownErrorOrUnknown(err)
+	}
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_feature_register
+func kustomer_feature_register(featureCString, exprCString *C.char) C.ulonglong {
+	err := libkustomer.RegisterFeature(C.GoString(featureCString), C.GoString(exprCString))
+	if err != nil {
+		return asKnownErrorOrUnknown(err)
+	}
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_feature_enabled
+func kustomer_feature_enabled(featureCString *C.char) C.int {
+	if libkustomer.FeatureEnabled(C.GoString(featureCString)) {
+		return 1
+	}
+	return 0
+}
+
 //export kustomer_err_numeric_text
 func kustomer_err_numeric_text(errNum C.ulonglong) *C.char {
 	err := asErrNumeric(errNum)
 	return C.CString(libkustomer.ErrNumericText(err))
 }
 
+//export kustomer_err_numeric_category
+func kustomer_err_numeric_category(errNum C.ulonglong) *C.char {
+	err := asErrNumeric(errNum)
+	return C.CString(string(err.Category()))
+}
+
+//export kustomer_err_numeric_retryable
+func kustomer_err_numeric_retryable(errNum C.ulonglong) C.int {
+	if asErrNumeric(errNum).Retryable() {
+		return 1
+	}
+	return 0
+}
+
+//export kustomer_err_numeric_denial
+func kustomer_err_numeric_denial(errNum C.ulonglong) C.int {
+	if asErrNumeric(errNum).Denial() {
+		return 1
+	}
+	return 0
+}
+
+//export kustomer_err_numeric_infrastructure
+func kustomer_err_numeric_infrastructure(errNum C.ulonglong) C.int {
+	if asErrNumeric(errNum).Infrastructure() {
+		return 1
+	}
+	return 0
+}
+
+//export kustomer_err_numeric_json
+func kustomer_err_numeric_json(errNum C.ulonglong) *C.char {
+	b, err := json.Marshal(asErrNumeric(errNum))
+	if err != nil {
+		return nil
+	}
+	return C.CString(string(b))
+}
+
 //export kustomer_begin_ensure
 func kustomer_begin_ensure() (statusNum C.ulonglong, transactionPtr unsafe.Pointer) {
 	kpc, err := libkustomer.CurrentKopanoProductClaims()
@@ -182,11 +394,59 @@ func kustomer_begin_ensure() (statusNum C.ulonglong, transactionPtr unsafe.Point
 		return asKnownErrorOrUnknown(err), nil
 	}
 
-	transactionPtr = pointer.Save(kpc)
+	transactionPtr = saveTransactionAsPointer(kpc)
 
 	return kustomer.StatusSuccess, transactionPtr
 }
 
+// kustomer_ensure_from_json restores an ensure transaction from a snapshot
+// created with kustomer_ensure_to_json. The HMAC key is passed as pointer and
+// length, so it can contain any bytes. Without key, the restored transaction
+// is never trusted. If maxAge is not zero, snapshots created more than maxAge
+// seconds ago are rejected. Restored transactions are always offline and must
+// be freed with kustomer_end_ensure.
+//
+//export kustomer_ensure_from_json
+func kustomer_ensure_from_json(snapshotCString *C.char, keyPtr unsafe.Pointer, keyLen C.int, maxAge C.ulonglong) (statusNum C.ulonglong, transactionPtr unsafe.Pointer) {
+	var key []byte
+	if keyPtr != nil && keyLen > 0 {
+		key = C.GoBytes(keyPtr, keyLen)
+	}
+
+	kpc, err := libkustomer.RestoreSnapshot([]byte(C.GoString(snapshotCString)), key, time.Duration(maxAge)*time.Second)
+	if err != nil {
+		return asKnownErrorOrUnknown(err), nil
+	}
+
+	transactionPtr = saveTransactionAsPointer(kpc)
+
+	return kustomer.StatusSuccess, transactionPtr
+}
+
+// kustomer_ensure_to_json returns the claims of the provided transaction as
+// JSON snapshot, signed with the HMAC key passed as pointer and length. The
+// returned string must be freed by the caller.
+//
+//export kustomer_ensure_to_json
+func kustomer_ensure_to_json(transactionPtr unsafe.Pointer, keyPtr unsafe.Pointer, keyLen C.int) (C.ulonglong, *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	var key []byte
+	if keyPtr != nil && keyLen > 0 {
+		key = C.GoBytes(keyPtr, keyLen)
+	}
+
+	b, err := t.begin().MarshalSignedJSON(key)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
 //export kustomer_instant_ensure
 func kustomer_instant_ensure(productNameCString, productUserAgentCString *C.char, timeout C.ulonglong) (statusNum C.ulonglong, transactionPtr unsafe.Pointer) {
 	var productName *string
@@ -205,16 +465,15 @@ func kustomer_instant_ensure(productNameCString, productUserAgentCString *C.char
 		return asKnownErrorOrUnknown(err), nil
 	}
 
-	transactionPtr = pointer.Save(kpc)
+	transactionPtr = saveTransactionAsPointer(kpc)
 
 	return kustomer.StatusSuccess, transactionPtr
 }
 
 //export kustomer_end_ensure
 func kustomer_end_ensure(transactionPtr unsafe.Pointer) C.ulonglong {
-	v := pointer.Restore(transactionPtr)
-	kpc, _ := v.(*kustomer.KopanoProductClaims)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 	pointer.Unref(transactionPtr)
@@ -222,32 +481,55 @@ func kustomer_end_ensure(transactionPtr unsafe.Pointer) C.ulonglong {
 	return kustomer.StatusSuccess
 }
 
-func restoreKopanoProductClaimsFromPointer(transactionPtr unsafe.Pointer) *kustomer.KopanoProductClaims {
-	v := pointer.Restore(transactionPtr)
-	kpc, _ := v.(*kustomer.KopanoProductClaims)
-	return kpc
+// kustomer_ensure_last_error returns the error of the most recent call on the
+// provided transaction. Every call on a transaction clears its last error, so
+// after a successful call it returns KUSTOMER_ERRSTATUSSUCCESS and NULL. After
+// a failed call, the returned status is the numeric code of the error and the
+// returned message its detailed text, which must be freed by the caller. Calls
+// with an invalid transaction have nothing to record the error with, so they
+// leave no last error.
+//
+//export kustomer_ensure_last_error
+func kustomer_ensure_last_error(transactionPtr unsafe.Pointer) (statusNum C.ulonglong, message *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	err := t.LastError()
+	if err == nil {
+		return kustomer.StatusSuccess, nil
+	}
+
+	return asKnownErrorOrUnknown(err), C.CString(err.Error())
 }
 
 //export kustomer_dump_ensure
 func kustomer_dump_ensure(transactionPtr unsafe.Pointer) (statusNum C.ulonglong, jsonBytes *C.char) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
 	}
 
-	m := kpc.Dump()
+	m := t.begin().Dump()
 	b, err := json.Marshal(m)
 	if err != nil {
-		return asKnownErrorOrUnknown(err), nil
+		return t.fail(err), nil
 	}
 
 	return kustomer.StatusSuccess, C.CString(string(b))
 }
 
+// kustomer_ensure_set_must_be_online changes the must be online flag of the
+// provided transaction for all its users.
+//
+// Deprecated: use kustomer_ensure_derive, which leaves the provided
+// transaction unchanged.
+//
 //export kustomer_ensure_set_must_be_online
 func kustomer_ensure_set_must_be_online(transactionPtr unsafe.Pointer, flagCInt C.int) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
@@ -255,15 +537,23 @@ func kustomer_ensure_set_must_be_online(transactionPtr unsafe.Pointer, flagCInt
 	if flagCInt != 0 {
 		flag = true
 	}
-	kpc.SetMustBeOnline(flag)
+	t.derive(func(kpc *kustomer.KopanoProductClaims) *kustomer.KopanoProductClaims {
+		return kpc.WithMustBeOnline(flag)
+	})
 
 	return kustomer.StatusSuccess
 }
 
+// kustomer_ensure_set_allow_untrusted changes the allow untrusted flag of the
+// provided transaction for all its users.
+//
+// Deprecated: use kustomer_ensure_derive, which leaves the provided
+// transaction unchanged.
+//
 //export kustomer_ensure_set_allow_untrusted
 func kustomer_ensure_set_allow_untrusted(transactionPtr unsafe.Pointer, flagCInt C.int) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
@@ -271,21 +561,54 @@ func kustomer_ensure_set_allow_untrusted(transactionPtr unsafe.Pointer, flagCInt
 	if flagCInt != 0 {
 		flag = true
 	}
-	kpc.SetAllowUntrusted(flag)
+	t.derive(func(kpc *kustomer.KopanoProductClaims) *kustomer.KopanoProductClaims {
+		return kpc.WithAllowUntrusted(flag)
+	})
 
 	return kustomer.StatusSuccess
 }
 
+// kustomer_ensure_derive returns a new transaction with the claims of the
+// provided transaction and the provided flags. The provided transaction is not
+// changed. The derived transaction must be freed with kustomer_end_ensure,
+// independently of the provided one.
+//
+//export kustomer_ensure_derive
+func kustomer_ensure_derive(transactionPtr unsafe.Pointer, mustBeOnlineCInt, allowUntrustedCInt C.int) (statusNum C.ulonglong, derivedTransactionPtr unsafe.Pointer) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	kpc := t.begin().WithMustBeOnline(mustBeOnlineCInt != 0).WithAllowUntrusted(allowUntrustedCInt != 0)
+	derivedTransactionPtr = saveTransactionAsPointer(kpc)
+
+	return kustomer.StatusSuccess, derivedTransactionPtr
+}
+
+//export kustomer_ensure_strict
+func kustomer_ensure_strict(transactionPtr unsafe.Pointer) (statusNum C.ulonglong, derivedTransactionPtr unsafe.Pointer) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	kpc := t.begin().WithoutSoftEnforcement()
+	derivedTransactionPtr = saveTransactionAsPointer(kpc)
+
+	return kustomer.StatusSuccess, derivedTransactionPtr
+}
+
 //export kustomer_ensure_ok
 func kustomer_ensure_ok(transactionPtr unsafe.Pointer, productNameCString *C.char) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
-	err := kpc.EnsureOK(C.GoString(productNameCString))
+	err := t.begin().EnsureOK(C.GoString(productNameCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -293,14 +616,14 @@ func kustomer_ensure_ok(transactionPtr unsafe.Pointer, productNameCString *C.cha
 
 //export kustomer_ensure_get_bool
 func kustomer_ensure_get_bool(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, C.int) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), 0
 	}
 
-	value, err := kpc.GetBool(C.GoString(productNameCString), C.GoString(claimCString))
+	value, err := t.begin().GetBool(C.GoString(productNameCString), C.GoString(claimCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err), 0
+		return t.fail(err), 0
 	}
 
 	var valueCInt C.int = 0
@@ -312,8 +635,8 @@ func kustomer_ensure_get_bool(transactionPtr unsafe.Pointer, productNameCString,
 
 //export kustomer_ensure_ensure_bool
 func kustomer_ensure_ensure_bool(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCInt C.int) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
@@ -321,9 +644,9 @@ func kustomer_ensure_ensure_bool(transactionPtr unsafe.Pointer, productNameCStri
 	if valueCInt != 0 {
 		value = true
 	}
-	err := kpc.EnsureBool(C.GoString(productNameCString), C.GoString(claimCString), value)
+	err := t.begin().EnsureBool(C.GoString(productNameCString), C.GoString(claimCString), value)
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -331,14 +654,14 @@ func kustomer_ensure_ensure_bool(transactionPtr unsafe.Pointer, productNameCStri
 
 //export kustomer_ensure_get_string
 func kustomer_ensure_get_string(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, *C.char) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
 	}
 
-	value, err := kpc.GetString(C.GoString(productNameCString), C.GoString(claimCString))
+	value, err := t.begin().GetString(C.GoString(productNameCString), C.GoString(claimCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err), nil
+		return t.fail(err), nil
 	}
 
 	return kustomer.StatusSuccess, C.CString(value)
@@ -346,14 +669,64 @@ func kustomer_ensure_get_string(transactionPtr unsafe.Pointer, productNameCStrin
 
 //export kustomer_ensure_ensure_string
 func kustomer_ensure_ensure_string(transactionPtr unsafe.Pointer, productNameCString, claimCString, valueCString *C.char) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
-	err := kpc.EnsureString(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(valueCString))
+	err := t.begin().EnsureString(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(valueCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_string_op
+func kustomer_ensure_ensure_string_op(transactionPtr unsafe.Pointer, productNameCString, claimCString, valueCString *C.char, opCode C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	op := getOperatorFromCode(int(opCode))
+	if op == nil {
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
+	}
+
+	err := t.begin().EnsureStringWithOperator(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(valueCString), *op)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_version_constraint
+func kustomer_ensure_ensure_version_constraint(transactionPtr unsafe.Pointer, productNameCString, claimCString, versionCString *C.char) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureVersionConstraint(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(versionCString))
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_version_satisfies
+func kustomer_ensure_ensure_version_satisfies(transactionPtr unsafe.Pointer, productNameCString, claimCString, constraintCString *C.char) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureVersionSatisfies(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(constraintCString))
+	if err != nil {
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -361,14 +734,14 @@ func kustomer_ensure_ensure_string(transactionPtr unsafe.Pointer, productNameCSt
 
 //export kustomer_ensure_get_int64
 func kustomer_ensure_get_int64(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, C.longlong) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), 0
 	}
 
-	value, err := kpc.GetInt64(C.GoString(productNameCString), C.GoString(claimCString))
+	value, err := t.begin().GetInt64(C.GoString(productNameCString), C.GoString(claimCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err), 0
+		return t.fail(err), 0
 	}
 
 	return kustomer.StatusSuccess, C.longlong(value)
@@ -376,14 +749,14 @@ func kustomer_ensure_get_int64(transactionPtr unsafe.Pointer, productNameCString
 
 //export kustomer_ensure_ensure_int64
 func kustomer_ensure_ensure_int64(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCLongLong C.longlong) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
-	err := kpc.EnsureInt64(C.GoString(productNameCString), C.GoString(claimCString), int64(valueCLongLong))
+	err := t.begin().EnsureInt64(C.GoString(productNameCString), C.GoString(claimCString), int64(valueCLongLong))
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -394,6 +767,13 @@ var operatorCodeArray = []kustomer.OperatorType{
 	kustomer.OperatorGreaterThanOrEqual,
 	kustomer.OperatorLesserThan,
 	kustomer.OperatorLesserThanOrEqual,
+	kustomer.OperatorEqualFold,
+	kustomer.OperatorHasPrefix,
+	kustomer.OperatorHasSuffix,
+	kustomer.OperatorGlob,
+	kustomer.OperatorRegexp,
+	kustomer.OperatorEqual,
+	kustomer.OperatorNotEqual,
 }
 
 func getOperatorFromCode(opCode int) *kustomer.OperatorType {
@@ -407,19 +787,19 @@ func getOperatorFromCode(opCode int) *kustomer.OperatorType {
 
 //export kustomer_ensure_ensure_int64_op
 func kustomer_ensure_ensure_int64_op(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCLongLong C.longlong, opCode C.int) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
 	op := getOperatorFromCode(int(opCode))
 	if op == nil {
-		return asKnownErrorOrUnknown(kustomer.ErrEnsureUnknownOperator)
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
 	}
 
-	err := kpc.EnsureInt64WithOperator(C.GoString(productNameCString), C.GoString(claimCString), int64(valueCLongLong), *op)
+	err := t.begin().EnsureInt64WithOperator(C.GoString(productNameCString), C.GoString(claimCString), int64(valueCLongLong), *op)
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -427,14 +807,14 @@ func kustomer_ensure_ensure_int64_op(transactionPtr unsafe.Pointer, productNameC
 
 //export kustomer_ensure_get_float64
 func kustomer_ensure_get_float64(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, C.double) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), 0
 	}
 
-	value, err := kpc.GetFloat64(C.GoString(productNameCString), C.GoString(claimCString))
+	value, err := t.begin().GetFloat64(C.GoString(productNameCString), C.GoString(claimCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err), 0
+		return t.fail(err), 0
 	}
 
 	return kustomer.StatusSuccess, C.double(value)
@@ -442,14 +822,14 @@ func kustomer_ensure_get_float64(transactionPtr unsafe.Pointer, productNameCStri
 
 //export kustomer_ensure_ensure_float64
 func kustomer_ensure_ensure_float64(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCDouble C.double) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
-	err := kpc.EnsureFloat64(C.GoString(productNameCString), C.GoString(claimCString), float64(valueCDouble))
+	err := t.begin().EnsureFloat64(C.GoString(productNameCString), C.GoString(claimCString), float64(valueCDouble))
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
@@ -457,55 +837,414 @@ func kustomer_ensure_ensure_float64(transactionPtr unsafe.Pointer, productNameCS
 
 //export kustomer_ensure_ensure_float64_op
 func kustomer_ensure_ensure_float64_op(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCDouble C.double, opCode C.int) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
 	op := getOperatorFromCode(int(opCode))
 	if op == nil {
-		return asKnownErrorOrUnknown(kustomer.ErrEnsureUnknownOperator)
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
 	}
 
-	err := kpc.EnsureFloat64WithOperator(C.GoString(productNameCString), C.GoString(claimCString), float64(valueCDouble), *op)
+	err := t.begin().EnsureFloat64WithOperator(C.GoString(productNameCString), C.GoString(claimCString), float64(valueCDouble), *op)
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_float64_tolerance
+func kustomer_ensure_ensure_float64_tolerance(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCDouble, toleranceCDouble C.double) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureFloat64WithTolerance(C.GoString(productNameCString), C.GoString(claimCString), float64(valueCDouble), float64(toleranceCDouble))
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_int64_range
+func kustomer_ensure_ensure_int64_range(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, minCLongLong, maxCLongLong C.longlong, inclusive C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureInt64InRange(C.GoString(productNameCString), C.GoString(claimCString), int64(minCLongLong), int64(maxCLongLong), inclusive != 0)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_float64_range
+func kustomer_ensure_ensure_float64_range(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, minCDouble, maxCDouble C.double, inclusive C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureFloat64InRange(C.GoString(productNameCString), C.GoString(claimCString), float64(minCDouble), float64(maxCDouble), inclusive != 0)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_get_time
+func kustomer_ensure_get_time(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, C.longlong) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), 0
+	}
+
+	value, err := t.begin().GetTime(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), 0
+	}
+
+	return kustomer.StatusSuccess, C.longlong(value.Unix())
+}
+
+//export kustomer_ensure_get_duration
+func kustomer_ensure_get_duration(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, C.longlong) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), 0
+	}
+
+	value, err := t.begin().GetDuration(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), 0
+	}
+
+	return kustomer.StatusSuccess, C.longlong(value / time.Second)
+}
+
+//export kustomer_ensure_ensure_time_op
+func kustomer_ensure_ensure_time_op(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valueCLongLong C.longlong, opCode C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	op := getOperatorFromCode(int(opCode))
+	if op == nil {
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
+	}
+
+	err := t.begin().EnsureTimeWithOperator(C.GoString(productNameCString), C.GoString(claimCString), time.Unix(int64(valueCLongLong), 0), *op)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_not_expired
+func kustomer_ensure_ensure_not_expired(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, nowCLongLong C.longlong) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	var now time.Time
+	if nowCLongLong != 0 {
+		now = time.Unix(int64(nowCLongLong), 0)
+	}
+	err := t.begin().EnsureNotExpired(C.GoString(productNameCString), C.GoString(claimCString), now)
+	if err != nil {
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
 }
 
 //export kustomer_ensure_get_stringArray_json
-func kustomer_ensure_get_stringArray_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonBytes unsafe.Pointer) {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+func kustomer_ensure_get_stringArray_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonCString *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
 	}
 
-	value, err := kpc.GetStringArrayValues(C.GoString(productNameCString), C.GoString(claimCString))
+	value, err := t.begin().GetStringArrayValues(C.GoString(productNameCString), C.GoString(claimCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err), nil
+		return t.fail(err), nil
 	}
 
 	b, err := json.Marshal(value)
 	if err != nil {
-		return asKnownErrorOrUnknown(err), nil
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_get_int64Array_json
+func kustomer_ensure_get_int64Array_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonCString *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	value, err := t.begin().GetInt64Array(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), nil
 	}
 
-	return kustomer.StatusSuccess, C.CBytes(b)
+	b, err := json.Marshal(value)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_get_float64Array_json
+func kustomer_ensure_get_float64Array_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonCString *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	value, err := t.begin().GetFloat64Array(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_get_object_json
+func kustomer_ensure_get_object_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonCString *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	value, err := t.begin().GetObject(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_get_stringMap_json
+func kustomer_ensure_get_stringMap_json(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (statusNum C.ulonglong, jsonCString *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	value, err := t.begin().GetStringMap(C.GoString(productNameCString), C.GoString(claimCString))
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
 }
 
 //export kustomer_ensure_ensure_stringArray_value
 func kustomer_ensure_ensure_stringArray_value(transactionPtr unsafe.Pointer, productNameCString, claimCString, valueCString *C.char) C.ulonglong {
-	kpc := restoreKopanoProductClaimsFromPointer(transactionPtr)
-	if kpc == nil {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
 		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
 	}
 
-	err := kpc.EnsureStringArrayValues(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(valueCString))
+	err := t.begin().EnsureStringArrayValues(C.GoString(productNameCString), C.GoString(claimCString), C.GoString(valueCString))
 	if err != nil {
-		return asKnownErrorOrUnknown(err)
+		return t.fail(err)
 	}
 
 	return kustomer.StatusSuccess
 }
+
+var stringArrayMatchArray = []func(kpc *kustomer.KopanoProductClaims, product, claim string, value ...string) error{
+	(*kustomer.KopanoProductClaims).EnsureStringArrayValues,
+	(*kustomer.KopanoProductClaims).EnsureStringArrayAny,
+	(*kustomer.KopanoProductClaims).EnsureStringArrayNone,
+	(*kustomer.KopanoProductClaims).EnsureStringArrayEquals,
+	(*kustomer.KopanoProductClaims).EnsureStringArraySubsetOf,
+}
+
+func getStringArrayMatchFromCode(matchCode int) func(kpc *kustomer.KopanoProductClaims, product, claim string, value ...string) error {
+	if matchCode < 1 || matchCode > len(stringArrayMatchArray) {
+		return nil
+	}
+
+	return stringArrayMatchArray[matchCode-1]
+}
+
+//export kustomer_ensure_ensure_stringArray_json
+func kustomer_ensure_ensure_stringArray_json(transactionPtr unsafe.Pointer, productNameCString, claimCString, valuesJSONCString *C.char, matchCode C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	match := getStringArrayMatchFromCode(int(matchCode))
+	if match == nil {
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
+	}
+
+	var values []string
+	if err := json.Unmarshal([]byte(C.GoString(valuesJSONCString)), &values); err != nil {
+		return t.fail(kustomer.ErrEnsureInvalidValue)
+	}
+
+	err := match(t.begin(), C.GoString(productNameCString), C.GoString(claimCString), values...)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_ensure_stringArray_list
+func kustomer_ensure_ensure_stringArray_list(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char, valuesCStrings **C.char, matchCode C.int) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	match := getStringArrayMatchFromCode(int(matchCode))
+	if match == nil {
+		return t.fail(kustomer.ErrEnsureUnknownOperator)
+	}
+	if valuesCStrings == nil {
+		return t.fail(kustomer.ErrEnsureInvalidValue)
+	}
+
+	// Values is a NULL terminated list of C strings.
+	var values []string
+	for p := valuesCStrings; *p != nil; p = (**C.char)(unsafe.Add(unsafe.Pointer(p), unsafe.Sizeof(*p))) {
+		values = append(values, C.GoString(*p))
+	}
+
+	err := match(t.begin(), C.GoString(productNameCString), C.GoString(claimCString), values...)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_product_names_json
+func kustomer_ensure_product_names_json(transactionPtr unsafe.Pointer) (C.ulonglong, *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	b, err := json.Marshal(t.begin().ProductNames())
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_product_claim_names_json
+func kustomer_ensure_product_claim_names_json(transactionPtr unsafe.Pointer, productNameCString *C.char) (C.ulonglong, *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	product, err := t.begin().Product(C.GoString(productNameCString))
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	b, err := json.Marshal(product.ClaimNames())
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
+
+//export kustomer_ensure_product_claim_kind
+func kustomer_ensure_product_claim_kind(transactionPtr unsafe.Pointer, productNameCString, claimCString *C.char) (C.ulonglong, *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	productName, claim := C.GoString(productNameCString), C.GoString(claimCString)
+	product, err := t.begin().Product(productName)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	kind := product.ClaimKind(claim)
+	if kind == kustomer.ClaimKindNone {
+		return t.fail(&kustomer.EnsureError{Err: kustomer.ErrEnsureProductClaimNotFound, Product: productName, Claim: claim}), nil
+	}
+
+	return kustomer.StatusSuccess, C.CString(string(kind))
+}
+
+//export kustomer_ensure_expr
+func kustomer_ensure_expr(transactionPtr unsafe.Pointer, exprCString *C.char) C.ulonglong {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction)
+	}
+
+	err := t.begin().EnsureExpr(C.GoString(exprCString))
+	if err != nil {
+		return t.fail(err)
+	}
+
+	return kustomer.StatusSuccess
+}
+
+//export kustomer_ensure_policy_file
+func kustomer_ensure_policy_file(transactionPtr unsafe.Pointer, policyFileCString *C.char) (statusNum C.ulonglong, jsonBytes *C.char) {
+	t := restoreTransactionFromPointer(transactionPtr)
+	if t == nil {
+		return asKnownErrorOrUnknown(kustomer.ErrEnsureInvalidTransaction), nil
+	}
+
+	policy, err := kustomer.LoadPolicyFile(C.GoString(policyFileCString))
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	report := policy.Evaluate(t.begin())
+	b, err := json.Marshal(report)
+	if err != nil {
+		return t.fail(err), nil
+	}
+
+	if err = report.Err(); err != nil {
+		return t.fail(err), C.CString(string(b))
+	}
+	return kustomer.StatusSuccess, C.CString(string(b))
+}
@@ -14,3 +14,8 @@ void bridge_kustomer_watch_cb_func_updated(kustomer_cb_func_watch f)
 {
 	return f();
 }
+
+void bridge_kustomer_expiry_cb_func_approaching(kustomer_cb_func_expiry f, char* product, long long expiry, long long remaining, long long threshold)
+{
+	return f(product, expiry, remaining, threshold);
+}
@@ -7,21 +7,21 @@ package main
 
 import "C"
 import (
+	"errors"
 	"fmt"
 
 	kustomer "stash.kopano.io/kc/libkustomer"
 )
 
 func asKnownErrorOrUnknown(err error) C.ulonglong {
-	switch e := err.(type) {
-	case kustomer.ErrNumeric:
-		return C.ulonglong(e)
-	default:
-		if debug {
-			fmt.Printf("kustomer-c unknown error: %s\n", err)
-		}
-		return C.ulonglong(kustomer.ErrStatusUnknown)
+	var errNumeric kustomer.ErrNumeric
+	if errors.As(err, &errNumeric) {
+		return C.ulonglong(errNumeric)
 	}
+	if debug {
+		fmt.Printf("kustomer-c unknown error: %s\n", err)
+	}
+	return C.ulonglong(kustomer.ErrStatusUnknown)
 }
 
 func asErrNumeric(errNum C.ulonglong) kustomer.ErrNumeric {
@@ -8,8 +8,10 @@
 
 typedef void (*kustomer_cb_func_log_s) (char*);
 typedef void (*kustomer_cb_func_watch) ();
+typedef void (*kustomer_cb_func_expiry) (char*, long long, long long, long long);
 
 void bridge_kustomer_log_cb_func_log_s(kustomer_cb_func_log_s f, char* s);
 void bridge_kustomer_watch_cb_func_updated(kustomer_cb_func_watch f);
+void bridge_kustomer_expiry_cb_func_approaching(kustomer_cb_func_expiry f, char* product, long long expiry, long long remaining, long long threshold);
 
 #endif /* !KUSTOMER_CALLBACKS_H */
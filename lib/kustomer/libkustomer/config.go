@@ -0,0 +1,324 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package libkustomer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+
+// Settings of this library are applied in the following order, later sources
+// overriding earlier ones:
+//
+//   1. Built-in defaults.
+//   2. The config file (InitOptions.ConfigFile or KUSTOMER_CONFIG).
+//   3. InitOptions as passed to Init.
+//   4. KUSTOMER_* environment variables.
+//   5. Calls to SetAutoRefresh, SetLogger and SetProductUserAgent after Init.
+//
+// The config file uses the key = value format of other Kopano config files.
+// Empty lines and lines starting with # or ; are ignored. Supported keys and
+// their matching environment variables are:
+//
+//   debug               KUSTOMER_DEBUG
+//   auto_refresh        KUSTOMER_AUTO_REFRESH
+//   product_user_agent  KUSTOMER_PRODUCT_USER_AGENT
+//   api_path            KUSTOMER_API_PATH
+//   fetch_timeout       KUSTOMER_FETCH_TIMEOUT
+//   retry_interval      KUSTOMER_RETRY_INTERVAL
+//   expiry_thresholds   KUSTOMER_EXPIRY_THRESHOLDS
+//
+// Boolean values accept yes/no, true/false, on/off and 1/0. As before the
+// config file was supported, any non-empty KUSTOMER_DEBUG value enables debug,
+// even values like 0 or no. Durations accept Go duration strings (like 30s) or
+// a plain number of seconds. Expiry thresholds are a comma separated list of
+// durations, which additionally accept a number of days (like 30d).
+//
+// Errors in the config file or the environment, including a missing config
+// file which was set explicitly, make Initialize and InstantEnsure fail with
+// ErrStatusInvalidConfig.
+
+// DefaultConfigFile is the config file which is loaded by Init if it exists and
+// no other config file was set.
+var DefaultConfigFile = "/etc/kopano/kustomer.cfg"
+
+// Config file keys.
+const (
+	configKeyDebug            = "debug"
+	configKeyAutoRefresh      = "auto_refresh"
+	configKeyProductUserAgent = "product_user_agent"
+	configKeyAPIPath          = "api_path"
+	configKeyFetchTimeout     = "fetch_timeout"
+	configKeyRetryInterval    = "retry_interval"
+	configKeyExpiryThresholds = "expiry_thresholds"
+)
+
+// configEnvMap maps config file keys to environment variable names.
+var configEnvMap = map[string]string{
+	configKeyDebug:            "KUSTOMER_DEBUG",
+	configKeyAutoRefresh:      "KUSTOMER_AUTO_REFRESH",
+	configKeyProductUserAgent: "KUSTOMER_PRODUCT_USER_AGENT",
+	configKeyAPIPath:          "KUSTOMER_API_PATH",
+	configKeyFetchTimeout:     "KUSTOMER_FETCH_TIMEOUT",
+	configKeyRetryInterval:    "KUSTOMER_RETRY_INTERVAL",
+	configKeyExpiryThresholds: "KUSTOMER_EXPIRY_THRESHOLDS",
+}
+
+// configKeys lists all supported keys in the order they are applied.
+var configKeys = []string{
+	configKeyDebug,
+	configKeyAutoRefresh,
+	configKeyProductUserAgent,
+	configKeyAPIPath,
+	configKeyFetchTimeout,
+	configKeyRetryInterval,
+	configKeyExpiryThresholds,
+}
+
+// parseConfigFile reads key = value pairs from the provided reader.
+func parseConfigFile(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, ";") {
+			continue
+		}
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid config line %d: missing =", line)
+		}
+		key := strings.TrimSpace(parts[0])
+		if _, ok := configEnvMap[key]; !ok {
+			return nil, fmt.Errorf("invalid config line %d: unknown key %s", line, key)
+		}
+		values[key] = strings.TrimSpace(parts[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// loadConfigFile reads the config file at the provided path. If required is
+// false, a missing file is not an error.
+func loadConfigFile(path string, required bool) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if !required && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	values, err := parseConfigFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true", "on", "1":
+		return true, nil
+	case "no", "false", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value: %s", value)
+}
+
+func parseConfigDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration value: %s", value)
+	}
+	return d, nil
+}
+
+func parseConfigDurations(value string) ([]time.Duration, error) {
+	durations := []time.Duration{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if days := strings.TrimSuffix(field, "d"); days != field {
+			if n, err := strconv.ParseUint(days, 10, 16); err == nil {
+				durations = append(durations, time.Duration(n)*24*time.Hour)
+				continue
+			}
+		}
+		d, err := parseConfigDuration(field)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// applyConfigValue sets the global library state for the provided key. It must
+// be called with the global mutex locked.
+func applyConfigValue(key, value string) error {
+	var err error
+	switch key {
+	case configKeyDebug:
+		debug, err = parseConfigBool(value)
+	case configKeyAutoRefresh:
+		autoRefresh, err = parseConfigBool(value)
+	case configKeyProductUserAgent:
+		if value == "" {
+			productUserAgent = nil
+		} else {
+			productUserAgent = &value
+		}
+	case configKeyAPIPath:
+		apiPath = value
+	case configKeyFetchTimeout:
+		fetchTimeout, err = parseConfigDuration(value)
+	case configKeyRetryInterval:
+		retryInterval, err = parseConfigDuration(value)
+	case configKeyExpiryThresholds:
+		expiryThresholds, err = parseConfigDurations(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// loadConfig applies the config file, the provided options and the environment
+// to the global library state. It must be called with the global mutex locked.
+func loadConfig(options *InitOptions) {
+	configErr = nil
+	configFile = DefaultConfigFile
+
+	path := DefaultConfigFile
+	required := false
+	if options != nil && options.ConfigFile != nil {
+		path = *options.ConfigFile
+		required = true
+	}
+	if e := os.Getenv("KUSTOMER_CONFIG"); e != "" {
+		path = e
+		required = true
+	}
+	configFile = path
+	if path != "" {
+		values, err := loadConfigFile(path, required)
+		if err != nil {
+			configErr = err
+		} else if values != nil {
+			for _, key := range configKeys {
+				if value, ok := values[key]; ok {
+					if err = applyConfigValue(key, value); err != nil && configErr == nil {
+						configErr = fmt.Errorf("%s: %w", path, err)
+					}
+				}
+			}
+		}
+	}
+
+	if options != nil {
+		if options.Debug {
+			debug = true
+		}
+		if options.AutoRefresh {
+			autoRefresh = true
+		}
+		if options.ProductUserAgent != nil {
+			productUserAgent = options.ProductUserAgent
+		}
+	}
+
+	for _, key := range configKeys {
+		value, ok := os.LookupEnv(configEnvMap[key])
+		if !ok || value == "" {
+			continue
+		}
+		if key == configKeyDebug {
+			// Any non-empty value enables debug, as it always did.
+			debug = true
+			continue
+		}
+		if err := applyConfigValue(key, value); err != nil {
+			if configErr == nil {
+				configErr = fmt.Errorf("%s: %w", configEnvMap[key], err)
+			}
+		}
+	}
+}
+
+// loadedConfigError returns the error of the last loaded config as
+// ErrStatusInvalidConfig, or nil. It must be called with the global mutex
+// locked.
+func loadedConfigError() error {
+	if configErr == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", kustomer.ErrStatusInvalidConfig, configErr)
+}
+
+// DumpConfig returns the effective configuration of the global library state.
+func DumpConfig() map[string]interface{} {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return dumpConfig()
+}
+
+func dumpConfig() map[string]interface{} {
+	m := map[string]interface{}{
+		configKeyDebug:            debug,
+		configKeyAutoRefresh:      autoRefresh,
+		configKeyProductUserAgent: nil,
+		configKeyAPIPath:          kustomer.DefaultAPIPath,
+		configKeyFetchTimeout:     kustomer.DefaultFetchTimeout.String(),
+		configKeyRetryInterval:    kustomer.DefaultRetryInterval.String(),
+		"config_file":             configFile,
+	}
+	if productUserAgent != nil {
+		m[configKeyProductUserAgent] = *productUserAgent
+	}
+	if apiPath != "" {
+		m[configKeyAPIPath] = apiPath
+	}
+	if fetchTimeout > 0 {
+		m[configKeyFetchTimeout] = fetchTimeout.String()
+	}
+	if retryInterval > 0 {
+		m[configKeyRetryInterval] = retryInterval.String()
+	}
+	thresholds := kustomer.DefaultExpiryThresholds
+	if expiryThresholds != nil {
+		thresholds = expiryThresholds
+	}
+	thresholdStrings := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		thresholdStrings[i] = threshold.String()
+	}
+	m[configKeyExpiryThresholds] = strings.Join(thresholdStrings, ",")
+	if configErr != nil {
+		m["config_error"] = configErr.Error()
+	}
+	return m
+}
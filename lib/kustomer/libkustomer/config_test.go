@@ -0,0 +1,138 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package libkustomer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	values, err := parseConfigFile(strings.NewReader(`
+# Comment
+; Other comment
+auto_refresh = yes
+product_user_agent=test/1.0
+fetch_timeout = 30
+retry_interval = 1m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[configKeyAutoRefresh] != "yes" || values[configKeyProductUserAgent] != "test/1.0" {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	for key, expected := range map[string]time.Duration{
+		configKeyFetchTimeout:  30 * time.Second,
+		configKeyRetryInterval: time.Minute,
+	} {
+		d, durationErr := parseConfigDuration(values[key])
+		if durationErr != nil || d != expected {
+			t.Errorf("unexpected duration for %s: %v (%v)", key, d, durationErr)
+		}
+	}
+
+	thresholds, err := parseConfigDurations("30d, 7d,1h30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 3 || thresholds[0] != 30*24*time.Hour || thresholds[1] != 7*24*time.Hour || thresholds[2] != 90*time.Minute {
+		t.Errorf("unexpected expiry thresholds: %v", thresholds)
+	}
+	if _, err = parseConfigDurations("30d,soon"); err == nil {
+		t.Error("expected error for invalid expiry threshold")
+	}
+
+	if _, err = parseConfigFile(strings.NewReader("unknown_key = 1\n")); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	if _, err = parseConfigFile(strings.NewReader("debug\n")); err == nil {
+		t.Error("expected error for line without value")
+	}
+}
+
+func resetConfig() {
+	debug = false
+	autoRefresh = false
+	productUserAgent = nil
+	apiPath = ""
+	fetchTimeout = 0
+	retryInterval = 0
+	expiryThresholds = nil
+	configFile = ""
+	configErr = nil
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	defer resetConfig()
+	for _, env := range configEnvMap {
+		t.Setenv(env, "")
+	}
+	t.Setenv("KUSTOMER_CONFIG", "")
+
+	path := filepath.Join(t.TempDir(), "kustomer.conf")
+	if err := os.WriteFile(path, []byte("debug = no\nauto_refresh = yes\nfetch_timeout = 30\napi_path = /file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUSTOMER_DEBUG", "0")
+	t.Setenv("KUSTOMER_API_PATH", "/env")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	resetConfig()
+	loadConfig(&InitOptions{ConfigFile: &path})
+	if configErr != nil {
+		t.Fatal(configErr)
+	}
+	if configFile != path || !autoRefresh || fetchTimeout != 30*time.Second {
+		t.Errorf("config file not applied: %v", dumpConfig())
+	}
+	if apiPath != "/env" {
+		t.Errorf("expected environment to override config file, got %s", apiPath)
+	}
+	if !debug {
+		t.Errorf("expected any non-empty KUSTOMER_DEBUG to enable debug")
+	}
+
+	// Options override the config file.
+	agent := "options"
+	if err := os.WriteFile(path, []byte("product_user_agent = file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resetConfig()
+	loadConfig(&InitOptions{ConfigFile: &path, ProductUserAgent: &agent})
+	if productUserAgent == nil || *productUserAgent != agent {
+		t.Errorf("expected options to override config file, got %v", dumpConfig())
+	}
+
+	// A later load without options does not keep the earlier config file.
+	loadConfig(nil)
+	if configFile != DefaultConfigFile {
+		t.Errorf("expected default config file after reload, got %s", configFile)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.conf")
+	resetConfig()
+	loadConfig(&InitOptions{ConfigFile: &missing})
+	if err := loadedConfigError(); !errors.Is(err, kustomer.ErrStatusInvalidConfig) {
+		t.Errorf("expected invalid config error for missing config file, got %v", err)
+	}
+
+	resetConfig()
+	t.Setenv("KUSTOMER_CONFIG", missing)
+	loadConfig(nil)
+	if configErr == nil {
+		t.Errorf("expected error for missing KUSTOMER_CONFIG file")
+	}
+}
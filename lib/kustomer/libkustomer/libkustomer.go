@@ -8,7 +8,6 @@ package libkustomer
 import (
 	"context"
 	"errors"
-	"os"
 	"sync"
 	"time"
 
@@ -25,16 +24,36 @@ var (
 	autoRefresh       = false
 	initializedLogger kustomer.Logger
 	productUserAgent  *string
+	apiPath           string
+	fetchTimeout      time.Duration
+	retryInterval     time.Duration
+	expiryThresholds  []time.Duration
+	clock             = kustomer.SystemClock
 	instance          *kustomer.Kustomer
 
+	configFile string
+	configErr  error
+
 	initializedContext       context.Context
 	initializedContextCancel context.CancelFunc
 
 	initializedNotifyCancel context.CancelFunc
+
+	featureGates = kustomer.NewFeatureGates()
+
+	auditMutex sync.RWMutex
+	auditSink  *kustomer.AuditFileSink
+	// auditSinkInitialized is true if auditSink was set while the global
+	// library state was initialized, so Uninitialize closes it.
+	auditSinkInitialized bool
+
+	expiryApproachingHandler func(*kustomer.ExpiryApproaching)
 )
 
 // Init early initializes this library and returns bool debug flag. This function
-// should be called before any other function of this library is used.
+// should be called before any other function of this library is used. Besides
+// the provided options, Init loads the config file and the KUSTOMER_*
+// environment variables.
 func Init(options *InitOptions) bool {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -43,27 +62,20 @@ func Init(options *InitOptions) bool {
 		panic(kustomer.ErrStatusAlreadyInitialized)
 	}
 
-	if options != nil {
-		if options.Debug {
-			debug = true
-		}
-		if options.AutoRefresh {
-			autoRefresh = true
-		}
-		if options.ProductUserAgent != nil {
-			productUserAgent = options.ProductUserAgent
-		}
-	}
-	if os.Getenv("KUSTOMER_DEBUG") != "" {
-
-		debug = true
+	if options != nil && options.Clock != nil {
+		clock = options.Clock
 	}
+	loadConfig(options)
 	if debug {
 		if options != nil && options.DefaultDebugLogger != nil {
 			initializedLogger = options.DefaultDebugLogger
 		} else {
 			initializedLogger = getDefaultDebugLogger()
 		}
+		initializedLogger.Printf("kustomer-c config: %v\n", dumpConfig())
+		if configErr != nil {
+			initializedLogger.Printf("kustomer-c config error: %v\n", configErr)
+		}
 	}
 
 	return debug
@@ -124,6 +136,82 @@ func SetProductUserAgent(ua *string) error {
 	return nil
 }
 
+// SetAuditFile sets the file to which every license decision is written as
+// JSON line. The file is rotated when it grows beyond maxSize bytes, keeping
+// maxBackups rotated files. Use an empty path to disable auditing. This
+// function can be called before and after Initialize. Transactions which were
+// started with auditing enabled write to the file which is current at the
+// time of the decision. A file set after Initialize is closed by Uninitialize,
+// a file set before Initialize stays open for later calls.
+func SetAuditFile(path string, maxSize int64, maxBackups int) error {
+	var sink *kustomer.AuditFileSink
+	if path != "" {
+		var err error
+		sink, err = kustomer.NewAuditFileSink(path, maxSize, maxBackups)
+		if err != nil {
+			return err
+		}
+	}
+
+	mutex.Lock()
+	previous := swapAuditSink(sink)
+	auditSinkInitialized = instance != nil
+	if instance != nil {
+		instance.SetAuditHook(auditHook())
+	}
+	mutex.Unlock()
+
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// AuditHook returns the audit hook of the global library state, or nil if
+// auditing is disabled. Use it for claims which were not returned by this
+// library, like restored snapshots.
+func AuditHook() kustomer.AuditHook {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return auditHook()
+}
+
+// auditHook returns the audit hook which writes to the current audit sink, or
+// nil if auditing is disabled. It must be called with the global mutex locked.
+func auditHook() kustomer.AuditHook {
+	auditMutex.RLock()
+	defer auditMutex.RUnlock()
+
+	if auditSink == nil {
+		return nil
+	}
+	return audit
+}
+
+// audit writes the provided record to the current audit sink. The audit mutex
+// is held while writing, so a replaced sink is only closed after all pending
+// writes are done.
+func audit(record *kustomer.AuditRecord) {
+	auditMutex.RLock()
+	defer auditMutex.RUnlock()
+
+	if auditSink != nil {
+		auditSink.Audit(record)
+	}
+}
+
+// swapAuditSink replaces the current audit sink and returns the previous one,
+// which is no longer written to once this function returns.
+func swapAuditSink(sink *kustomer.AuditFileSink) *kustomer.AuditFileSink {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	previous := auditSink
+	auditSink = sink
+	return previous
+}
+
 // Initialize initializes the global library state with the provided product
 // name. Use nil productName to initialize for all products. The initialization
 // is bound to the provided context and resources are relased when it is done.
@@ -139,6 +227,13 @@ func Initialize(ctx context.Context, productName *string) error {
 		initializedLogger = getDefaultDebugLogger()
 	}
 
+	if err := loadedConfigError(); err != nil {
+		if debug {
+			initializedLogger.Printf("kustomer-c initialize failed: %v\n", err)
+		}
+		return err
+	}
+
 	k, err := kustomer.New(&kustomer.Config{
 		Logger: initializedLogger,
 
@@ -146,6 +241,16 @@ func Initialize(ctx context.Context, productName *string) error {
 		AutoRefresh: autoRefresh,
 
 		ProductUserAgent: productUserAgent,
+
+		APIPath:       apiPath,
+		FetchTimeout:  fetchTimeout,
+		RetryInterval: retryInterval,
+
+		ExpiryThresholds: expiryThresholds,
+
+		Clock: clock,
+
+		AuditHook: auditHook(),
 	})
 	if err != nil {
 		if debug {
@@ -154,6 +259,9 @@ func Initialize(ctx context.Context, productName *string) error {
 		return err
 	}
 
+	// Register before Initialize, so events of the first fetch are not lost.
+	k.OnExpiryApproaching(notifyExpiryApproaching)
+
 	if debug {
 		initializedLogger.Printf("kustomer-c initializing (autoRefresh: %v, debug: %v)\n", autoRefresh, debug)
 	}
@@ -167,6 +275,12 @@ func Initialize(ctx context.Context, productName *string) error {
 
 	instance = k
 	initializedContext, initializedContextCancel = context.WithCancel(ctx)
+	go func(ctx context.Context) {
+		err := featureGates.Watch(ctx, k)
+		if debug {
+			initializedLogger.Printf("kustomer-c feature gates watch ended: %v\n", err)
+		}
+	}(initializedContext)
 	if debug {
 		var productNameString string
 		if productName != nil {
@@ -201,6 +315,15 @@ func Uninitialize() error {
 	initializedContextCancel = nil
 
 	instance = nil
+	featureGates.Reset()
+	if auditSinkInitialized {
+		auditSinkInitialized = false
+		if sink := swapAuditSink(nil); sink != nil {
+			if closeErr := sink.Close(); closeErr != nil && debug {
+				initializedLogger.Printf("kustomer-c uninitialize failed to close audit file: %v\n", closeErr)
+			}
+		}
+	}
 	if debug {
 		initializedLogger.Printf("kustomer-c uninitialize success\n")
 	}
@@ -213,6 +336,7 @@ func WaitUntilReady(timeout time.Duration) error {
 	mutex.RLock()
 	k := instance
 	ctx := initializedContext
+	c := clock
 	mutex.RUnlock()
 
 	var err error
@@ -223,7 +347,7 @@ func WaitUntilReady(timeout time.Duration) error {
 	if k == nil {
 		err = kustomer.ErrStatusNotInitialized
 	} else {
-		timeoutCtx, timeoutCtxCancel := context.WithTimeout(ctx, timeout)
+		timeoutCtx, timeoutCtxCancel := kustomer.WithClockTimeout(ctx, c, timeout)
 		err = k.WaitUntilReady(timeoutCtx)
 		timeoutCtxCancel()
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -267,6 +391,96 @@ func CurrentKopanoProductClaims() (*kustomer.KopanoProductClaims, error) {
 	return k.CurrentKopanoProductClaims(ctx), nil
 }
 
+// SetSoftEnforcement puts the provided product in soft enforcement mode until
+// the provided time, or without end if until is the zero time. See
+// kustomer.Kustomer.SetSoftEnforcement for details. The global library state
+// must have been initialized to use this function.
+func SetSoftEnforcement(product string, until time.Time) error {
+	mutex.RLock()
+	k := instance
+	mutex.RUnlock()
+
+	if k == nil {
+		return kustomer.ErrStatusNotInitialized
+	}
+
+	k.SetSoftEnforcement(product, until)
+	return nil
+}
+
+// ClearSoftEnforcement removes the provided product from soft enforcement
+// mode. The global library state must have been initialized to use this
+// function.
+func ClearSoftEnforcement(product string) error {
+	mutex.RLock()
+	k := instance
+	mutex.RUnlock()
+
+	if k == nil {
+		return kustomer.ErrStatusNotInitialized
+	}
+
+	k.ClearSoftEnforcement(product)
+	return nil
+}
+
+// SetExpiryApproachingHandler sets the provided function to be called with
+// every ExpiryApproaching event of the global library state instance. Pass nil
+// to remove the handler. This function can be called before and after
+// Initialize.
+func SetExpiryApproachingHandler(handler func(*kustomer.ExpiryApproaching)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	expiryApproachingHandler = handler
+}
+
+func notifyExpiryApproaching(event *kustomer.ExpiryApproaching) {
+	mutex.RLock()
+	handler := expiryApproachingHandler
+	mutex.RUnlock()
+
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// RegisterFeature registers the provided feature with the provided policy
+// expression in the global feature gates. Features can be registered before
+// and after Initialize.
+func RegisterFeature(feature, expression string) error {
+	return featureGates.RegisterExpr(feature, expression)
+}
+
+// FeatureEnabled returns true if the provided feature is registered in the
+// global feature gates and enabled with the current active claims. Without an
+// initialized global library state, no feature is enabled.
+func FeatureEnabled(feature string) bool {
+	mutex.RLock()
+	initialized := instance != nil
+	mutex.RUnlock()
+
+	return initialized && featureGates.Enabled(feature)
+}
+
+// RestoreSnapshot restores claims from the provided JSON snapshot like
+// kustomer.UnmarshalSignedJSONWithClock. The restored claims use the clock and
+// the audit hook of the global library state.
+func RestoreSnapshot(data, key []byte, maxAge time.Duration) (*kustomer.KopanoProductClaims, error) {
+	mutex.RLock()
+	c := clock
+	mutex.RUnlock()
+
+	kpc, err := kustomer.UnmarshalSignedJSONWithClock(data, key, maxAge, c)
+	if err != nil {
+		return nil, err
+	}
+	if hook := AuditHook(); hook != nil {
+		kpc = kpc.WithAuditHook(hook)
+	}
+	return kpc, nil
+}
+
 // InstanceEnsure is a way to start an ensure transaction without having to
 // initialize the global library strate. The transaction is bound to the
 // provided context and is using the provide product name and user agent
@@ -276,17 +490,30 @@ func CurrentKopanoProductClaims() (*kustomer.KopanoProductClaims, error) {
 func InstantEnsure(ctx context.Context,
 	productName, productUserAgent *string, timeout time.Duration) (*kustomer.KopanoProductClaims, error) {
 	mutex.RLock()
+	if err := loadedConfigError(); err != nil {
+		mutex.RUnlock()
+		return nil, err
+	}
 	logger := initializedLogger
-	mutex.RUnlock()
-
-	k, err := kustomer.New(&kustomer.Config{
+	config := &kustomer.Config{
 		Logger: logger,
 
 		Debug:       debug,
 		AutoRefresh: false,
 
 		ProductUserAgent: productUserAgent,
-	})
+
+		APIPath:       apiPath,
+		FetchTimeout:  fetchTimeout,
+		RetryInterval: retryInterval,
+
+		Clock: clock,
+
+		AuditHook: auditHook(),
+	}
+	mutex.RUnlock()
+
+	k, err := kustomer.New(config)
 	if err != nil {
 		if debug {
 			initializedLogger.Printf("kustomer-c begin instant ensure failed: %v\n", err)
@@ -307,7 +534,7 @@ func InstantEnsure(ctx context.Context,
 	}
 	defer k.Uninitialize() //nolint
 
-	timeoutCtx, timeoutCtxCancel := context.WithTimeout(ctx, timeout)
+	timeoutCtx, timeoutCtxCancel := kustomer.WithClockTimeout(ctx, config.Clock, timeout)
 	err = k.WaitUntilReady(timeoutCtx)
 	timeoutCtxCancel()
 	if err != nil {
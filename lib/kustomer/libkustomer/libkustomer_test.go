@@ -0,0 +1,194 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package libkustomer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+
+func TestFeatureEnabledRequiresInstance(t *testing.T) {
+	defer featureGates.Reset()
+
+	featureGates.Register("always", func(*kustomer.KopanoProductClaims) error {
+		return nil
+	})
+	featureGates.Evaluate(nil)
+	if !featureGates.Enabled("always") {
+		t.Fatal("expected feature gate to be enabled")
+	}
+
+	if FeatureEnabled("always") {
+		t.Errorf("expected feature to be disabled without initialized instance")
+	}
+	if err := Uninitialize(); err != kustomer.ErrStatusNotInitialized {
+		t.Errorf("unexpected uninitialize error: %v", err)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	key := []byte("se\x00cret")
+	kpc, err := kustomer.UnmarshalSignedJSON([]byte(`{"payload": {"trusted": true, "offline": false, "products": {"groupware": {"ok": true, "claims": {}}}}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := kpc.MarshalSignedJSON(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err = SetAuditFile(path, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAuditFile("", 0, 0) //nolint:errcheck
+
+	restored, err := RestoreSnapshot(signed, key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.EnsureOK("groupware") //nolint:errcheck
+	if b, _ := os.ReadFile(path); !strings.Contains(string(b), `"operation":"EnsureOK"`) {
+		t.Errorf("expected restored claims to be audited, got %q", b)
+	}
+	if err = restored.EnsureOnline(); !errors.Is(err, kustomer.ErrEnsureOnlineFailed) {
+		t.Errorf("expected restored claims to be offline, got %v", err)
+	}
+
+	// The full key is used, not only the bytes before the first NUL.
+	if _, err = RestoreSnapshot(signed, key[:2], time.Hour); !errors.Is(err, kustomer.ErrEnsureInvalidSnapshot) {
+		t.Errorf("expected truncated key to fail, got %v", err)
+	}
+}
+
+func TestAuditHookFollowsAuditFile(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+
+	if err := SetAuditFile(first, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAuditFile("", 0, 0) //nolint:errcheck
+
+	hook := AuditHook()
+	if hook == nil {
+		t.Fatal("expected audit hook")
+	}
+	if err := SetAuditFile(second, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hooks obtained before the change write to the current file.
+	hook(&kustomer.AuditRecord{Operation: "EnsureOK"})
+	if b, _ := os.ReadFile(second); !strings.Contains(string(b), `"operation":"EnsureOK"`) {
+		t.Errorf("expected record in current audit file, got %q", b)
+	}
+	if b, _ := os.ReadFile(first); len(b) != 0 {
+		t.Errorf("expected no record in previous audit file, got %q", b)
+	}
+
+	if err := SetAuditFile("", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if AuditHook() != nil {
+		t.Error("expected no audit hook with auditing disabled")
+	}
+	hook(&kustomer.AuditRecord{Operation: "EnsureOnline"})
+	if b, _ := os.ReadFile(second); strings.Contains(string(b), `"operation":"EnsureOnline"`) {
+		t.Errorf("expected no record with auditing disabled, got %q", b)
+	}
+}
+
+func TestTimeoutsUseClock(t *testing.T) {
+	t.Setenv("KUSTOMER_API_PATH", "")
+	fakeClock := kustomer.NewFakeClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	defer func(c kustomer.Clock, p string) {
+		clock, apiPath = c, p
+	}(clock, apiPath)
+	clock = fakeClock
+	apiPath = filepath.Join(t.TempDir(), "missing.sock")
+
+	// advanceUntil advances the clock until the provided function returns.
+	advanceUntil := func(f func() error) error {
+		result := make(chan error, 1)
+		go func() {
+			result <- f()
+		}()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case err := <-result:
+				return err
+			case <-deadline:
+				t.Fatal("timeout waiting for fake clock timeout")
+			case <-time.After(time.Millisecond):
+				fakeClock.Advance(time.Second)
+			}
+		}
+	}
+
+	if err := Initialize(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer Uninitialize() //nolint:errcheck
+	if err := advanceUntil(func() error {
+		return WaitUntilReady(time.Minute)
+	}); err != kustomer.ErrStatusTimeout {
+		t.Errorf("expected wait until ready to time out, got %v", err)
+	}
+
+	if err := advanceUntil(func() error {
+		_, err := InstantEnsure(context.Background(), nil, nil, time.Minute)
+		return err
+	}); err != kustomer.ErrStatusTimeout {
+		t.Errorf("expected instant ensure to time out, got %v", err)
+	}
+}
+
+func TestUninitializeKeepsEarlierAuditFile(t *testing.T) {
+	t.Setenv("KUSTOMER_API_PATH", "")
+	defer func(p string) {
+		apiPath = p
+	}(apiPath)
+	dir := t.TempDir()
+	apiPath = filepath.Join(dir, "missing.sock")
+
+	if err := SetAuditFile(filepath.Join(dir, "before.log"), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAuditFile("", 0, 0) //nolint:errcheck
+
+	if err := Initialize(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninitialize(); err != nil {
+		t.Fatal(err)
+	}
+	if AuditHook() == nil {
+		t.Fatal("expected audit file set before initialize to stay open")
+	}
+
+	if err := Initialize(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAuditFile(filepath.Join(dir, "after.log"), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninitialize(); err != nil {
+		t.Fatal(err)
+	}
+	if AuditHook() != nil {
+		t.Error("expected audit file set after initialize to be closed")
+	}
+}
@@ -15,5 +15,12 @@ type InitOptions struct {
 	AutoRefresh      bool
 	ProductUserAgent *string
 
+	// ConfigFile sets the config file to load instead of DefaultConfigFile. It
+	// is an error if this file does not exist.
+	ConfigFile *string
+
 	DefaultDebugLogger kustomer.Logger
+
+	// Clock overrides kustomer.SystemClock when not nil.
+	Clock kustomer.Clock
 }
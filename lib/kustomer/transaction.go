@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package main
+
+import "C"
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/mattn/go-pointer"
+
+	kustomer "stash.kopano.io/kc/libkustomer"
+)
+
+// A transaction is the state behind an ensure transaction pointer of the C
+// API. Besides the claims, it tracks the last error for detailed reporting.
+// The claims are never modified, changing flags replaces them with a derived
+// view, so the same transaction can be used from multiple threads.
+type transaction struct {
+	mutex sync.Mutex
+
+	kpc     *kustomer.KopanoProductClaims
+	lastErr error
+}
+
+func saveTransactionAsPointer(kpc *kustomer.KopanoProductClaims) unsafe.Pointer {
+	return pointer.Save(&transaction{
+		kpc: kpc,
+	})
+}
+
+func restoreTransactionFromPointer(transactionPtr unsafe.Pointer) *transaction {
+	v := pointer.Restore(transactionPtr)
+	t, _ := v.(*transaction)
+	return t
+}
+
+// begin starts a call on the associated transaction. It clears the last error,
+// so it only ever reflects the most recent call, and returns the claims.
+func (t *transaction) begin() *kustomer.KopanoProductClaims {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.lastErr = nil
+	return t.kpc
+}
+
+// derive replaces the claims of the associated transaction with the result of
+// the provided function.
+func (t *transaction) derive(fn func(*kustomer.KopanoProductClaims) *kustomer.KopanoProductClaims) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.kpc = fn(t.kpc)
+}
+
+// fail records the provided error as the last error of the associated
+// transaction and returns its numeric error code.
+func (t *transaction) fail(err error) C.ulonglong {
+	t.mutex.Lock()
+	t.lastErr = err
+	t.mutex.Unlock()
+
+	return asKnownErrorOrUnknown(err)
+}
+
+// LastError returns the last error recorded with fail.
+func (t *transaction) LastError() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.lastErr
+}
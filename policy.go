@@ -0,0 +1,345 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicySeverity defines how failing requirements of a Policy are treated.
+type PolicySeverity string
+
+// Policy severities. Only failing requirements with PolicySeverityError make
+// the PolicyReport fail.
+const (
+	PolicySeverityError   PolicySeverity = "error"
+	PolicySeverityWarning PolicySeverity = "warning"
+	PolicySeverityInfo    PolicySeverity = "info"
+)
+
+// Policy check types as used in the ensure field of PolicyCheck.
+const (
+	PolicyCheckOnline      = "online"
+	PolicyCheckTrusted     = "trusted"
+	PolicyCheckOK          = "ok"
+	PolicyCheckBool        = "bool"
+	PolicyCheckString      = "string"
+	PolicyCheckInt64       = "int64"
+	PolicyCheckFloat64     = "float64"
+	PolicyCheckStringArray = "stringArray"
+	PolicyCheckExpr        = "expr"
+)
+
+// A Policy is a named set of license requirements.
+type Policy struct {
+	Name         string               `json:"name"`
+	Requirements []*PolicyRequirement `json:"requirements"`
+}
+
+// A PolicyRequirement is a named list of checks which all must pass.
+type PolicyRequirement struct {
+	Name     string         `json:"name"`
+	Severity PolicySeverity `json:"severity,omitempty"`
+	Checks   []*PolicyCheck `json:"checks"`
+}
+
+// A PolicyCheck describes a single ensure check. Ensure selects the check type
+// and the other fields are the parameters of the matching Ensure* function of
+// KopanoProductClaims.
+type PolicyCheck struct {
+	Ensure   string       `json:"ensure"`
+	Product  string       `json:"product,omitempty"`
+	Claim    string       `json:"claim,omitempty"`
+	Operator OperatorType `json:"operator,omitempty"`
+	Value    interface{}  `json:"value,omitempty"`
+	Expr     string       `json:"expr,omitempty"`
+
+	ensure func(kpc *KopanoProductClaims) error
+}
+
+// A PolicyReport is the result of evaluating a Policy.
+type PolicyReport struct {
+	Policy       string                     `json:"policy"`
+	OK           bool                       `json:"ok"`
+	Requirements []*PolicyRequirementReport `json:"requirements"`
+}
+
+// A PolicyRequirementReport is the result of evaluating a PolicyRequirement.
+// If the requirement did not pass, Error is the error of the first failing
+// check, Message its detailed text and Product and Claim are the parameters of
+// that check, or for expressions the product and claim which failed.
+type PolicyRequirementReport struct {
+	Name     string         `json:"name"`
+	Severity PolicySeverity `json:"severity"`
+	Passed   bool           `json:"passed"`
+	Error    ErrNumeric     `json:"error,omitempty"`
+	Message  string         `json:"message,omitempty"`
+	Product  string         `json:"product,omitempty"`
+	Claim    string         `json:"claim,omitempty"`
+}
+
+// ParsePolicy parses the provided JSON or YAML data as Policy. As JSON is valid
+// YAML, YAML is always accepted.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("policy parse error: %w", err)
+	}
+	// Round trip through JSON, to only have a single set of field names.
+	b, err := json.Marshal(yamlToJSONValue(raw))
+	if err != nil {
+		return nil, fmt.Errorf("policy parse error: %w", err)
+	}
+	// Decode numbers as json.Number, so int64 values keep their precision.
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	p := &Policy{}
+	if err = decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("policy parse error: %w", err)
+	}
+
+	if err = p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadPolicyFile reads and parses the policy file at the provided path.
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return p, nil
+}
+
+func yamlToJSONValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for k, mv := range tv {
+			m[fmt.Sprintf("%v", k)] = yamlToJSONValue(mv)
+		}
+		return m
+	case []interface{}:
+		for i, iv := range tv {
+			tv[i] = yamlToJSONValue(iv)
+		}
+	}
+	return v
+}
+
+func (p *Policy) compile() error {
+	for i, r := range p.Requirements {
+		if r.Name == "" {
+			return fmt.Errorf("policy requirement %d has no name", i)
+		}
+		switch r.Severity {
+		case "":
+			r.Severity = PolicySeverityError
+		case PolicySeverityError, PolicySeverityWarning, PolicySeverityInfo:
+		default:
+			return fmt.Errorf("policy requirement %s has unknown severity: %s", r.Name, r.Severity)
+		}
+		for j, c := range r.Checks {
+			if err := c.compile(); err != nil {
+				return fmt.Errorf("policy requirement %s check %d: %w", r.Name, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *PolicyCheck) compile() error { //nolint:gocyclo
+	if c.Ensure != PolicyCheckOnline && c.Ensure != PolicyCheckTrusted && c.Ensure != PolicyCheckExpr {
+		if c.Product == "" {
+			return errors.New("missing product")
+		}
+		if c.Ensure != PolicyCheckOK && c.Claim == "" {
+			return errors.New("missing claim")
+		}
+	}
+	if c.Operator != "" && c.Ensure != PolicyCheckString && c.Ensure != PolicyCheckInt64 && c.Ensure != PolicyCheckFloat64 {
+		return fmt.Errorf("operator not supported for %s", c.Ensure)
+	}
+
+	switch c.Ensure {
+	case PolicyCheckOnline:
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			return kpc.EnsureOnline()
+		}
+	case PolicyCheckTrusted:
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			return kpc.EnsureTrusted()
+		}
+	case PolicyCheckOK:
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			return kpc.EnsureOK(c.Product)
+		}
+	case PolicyCheckBool:
+		value, ok := c.Value.(bool)
+		if !ok {
+			return errors.New("value must be a bool")
+		}
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			return kpc.EnsureBool(c.Product, c.Claim, value)
+		}
+	case PolicyCheckString:
+		value, ok := c.Value.(string)
+		if !ok {
+			return errors.New("value must be a string")
+		}
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			if c.Operator == "" {
+				return kpc.EnsureString(c.Product, c.Claim, value)
+			}
+			return kpc.EnsureStringWithOperator(c.Product, c.Claim, value, c.Operator)
+		}
+	case PolicyCheckInt64:
+		value, ok := claimInt64(c.Value)
+		if !ok {
+			return errors.New("value must be an integer")
+		}
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			if c.Operator == "" {
+				return kpc.EnsureInt64(c.Product, c.Claim, value)
+			}
+			return kpc.EnsureInt64WithOperator(c.Product, c.Claim, value, c.Operator)
+		}
+	case PolicyCheckFloat64:
+		value, ok := claimNumber(c.Value)
+		if !ok {
+			return errors.New("value must be a number")
+		}
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			if c.Operator == "" {
+				return kpc.EnsureFloat64(c.Product, c.Claim, value)
+			}
+			return kpc.EnsureFloat64WithOperator(c.Product, c.Claim, value, c.Operator)
+		}
+	case PolicyCheckStringArray:
+		var values []string
+		switch tv := c.Value.(type) {
+		case string:
+			values = []string{tv}
+		case []interface{}:
+			for _, iv := range tv {
+				s, ok := iv.(string)
+				if !ok {
+					return errors.New("value must be a string or a list of strings")
+				}
+				values = append(values, s)
+			}
+		default:
+			return errors.New("value must be a string or a list of strings")
+		}
+		c.ensure = func(kpc *KopanoProductClaims) error {
+			return kpc.EnsureStringArrayValues(c.Product, c.Claim, values...)
+		}
+	case PolicyCheckExpr:
+		e, err := ParseExpr(c.Expr)
+		if err != nil {
+			return err
+		}
+		c.ensure = e.Ensure
+	default:
+		return fmt.Errorf("unknown ensure type: %s", c.Ensure)
+	}
+	return nil
+}
+
+// Evaluate evaluates the associated Policy with the provided claims and
+// returns the resulting report.
+func (p *Policy) Evaluate(kpc *KopanoProductClaims) *PolicyReport {
+	report := &PolicyReport{
+		Policy:       p.Name,
+		OK:           true,
+		Requirements: make([]*PolicyRequirementReport, 0, len(p.Requirements)),
+	}
+
+	for _, r := range p.Requirements {
+		rr := &PolicyRequirementReport{
+			Name:     r.Name,
+			Severity: r.Severity,
+			Passed:   true,
+		}
+		for _, c := range r.Checks {
+			if err := c.ensure(kpc); err != nil {
+				rr.Passed = false
+				rr.Error = asErrNumeric(err)
+				rr.Message = err.Error()
+				rr.Product = c.Product
+				rr.Claim = c.Claim
+				var ensureErr *EnsureError
+				if rr.Product == "" && errors.As(err, &ensureErr) {
+					// Expressions and state checks have no parameters, so
+					// report the product and claim of the failing check.
+					rr.Product = ensureErr.Product
+					rr.Claim = ensureErr.Claim
+				}
+				break
+			}
+		}
+		if !rr.Passed && rr.Severity == PolicySeverityError {
+			report.OK = false
+		}
+		report.Requirements = append(report.Requirements, rr)
+	}
+
+	return report
+}
+
+// Err returns the error of the first failing requirement with
+// PolicySeverityError of the associated report, or nil if the report is OK.
+func (report *PolicyReport) Err() error {
+	for _, rr := range report.Requirements {
+		if !rr.Passed && rr.Severity == PolicySeverityError {
+			return rr.Error
+		}
+	}
+	return nil
+}
+
+func asErrNumeric(err error) ErrNumeric {
+	var errNumeric ErrNumeric
+	if errors.As(err, &errNumeric) {
+		return errNumeric
+	}
+	return ErrStatusUnknown
+}
+
+// WatchPolicyFile loads the policy file at the provided path and evaluates it
+// with the active claims of the associated instance. The file is reloaded and
+// evaluated again whenever the claims have been updated. The resulting report,
+// or the error if the policy file could not be loaded, is passed to the
+// provided callback. Calling this function blocks until the provided context
+// is done or until the associated instance is uninitialized.
+func (k *Kustomer) WatchPolicyFile(ctx context.Context, path string, cb func(*PolicyReport, error)) error {
+	return k.watchUpdates(ctx, func(kpc *KopanoProductClaims) {
+		p, err := LoadPolicyFile(path)
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(p.Evaluate(kpc), nil)
+	})
+}
@@ -0,0 +1,89 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var testPolicyYAML = `
+name: groupware
+requirements:
+  - name: licensed
+    checks:
+      - ensure: ok
+        product: groupware
+      - ensure: int64
+        product: groupware
+        claim: max_users
+        operator: ge
+        value: 50
+  - name: archiver
+    severity: warning
+    checks:
+      - ensure: stringArray
+        product: groupware
+        claim: features
+        value: [archiver, kdav]
+  - name: meet
+    checks:
+      - ensure: expr
+        expr: online && meet.ok
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := policy.Evaluate(newTestKopanoProductClaims())
+	if report.OK {
+		t.Error("expected report to fail")
+	}
+	if err = report.Err(); err != ErrEnsureProductNotLicensed {
+		t.Errorf("unexpected report error: %v", err)
+	}
+
+	expected := []struct {
+		passed  bool
+		err     ErrNumeric
+		product string
+		claim   string
+	}{
+		{true, 0, "", ""},
+		{false, ErrEnsureProductClaimValueMismatch, "groupware", "features"},
+		{false, ErrEnsureProductNotLicensed, "meet", ""},
+	}
+	for i, rr := range report.Requirements {
+		if rr.Passed != expected[i].passed || rr.Error != expected[i].err || rr.Product != expected[i].product || rr.Claim != expected[i].claim {
+			t.Errorf("unexpected requirement report %s: %+v", rr.Name, rr)
+		}
+	}
+
+	if _, err = ParsePolicy([]byte(`{"requirements": [{"name": "x", "checks": [{"ensure": "int64", "product": "p", "claim": "c", "value": "1"}]}]}`)); err == nil {
+		t.Error("expected error for invalid value type")
+	}
+}
+
+func TestPolicyInt64Precision(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Products["groupware"].Claims["serial"] = json.Number("9007199254740993")
+
+	for value, passed := range map[string]bool{
+		"9007199254740993": true,
+		"9007199254740992": false,
+	} {
+		policy, err := ParsePolicy([]byte(`{"requirements": [{"name": "serial", "checks": [{"ensure": "int64", "product": "groupware", "claim": "serial", "value": ` + value + `}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report := policy.Evaluate(kpc); report.OK != passed {
+			t.Errorf("%s: expected passed %v, got %+v", value, passed, report.Requirements[0])
+		}
+	}
+}
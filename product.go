@@ -0,0 +1,113 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"encoding/json"
+	"sort"
+
+	api "stash.kopano.io/kgol/kustomer/server/api-v1"
+)
+
+// ClaimKind is the JSON kind of a claim value.
+type ClaimKind string
+
+// Claim kinds as returned by Product.ClaimKind.
+const (
+	ClaimKindNone   ClaimKind = ""
+	ClaimKindNull   ClaimKind = "null"
+	ClaimKindBool   ClaimKind = "bool"
+	ClaimKindString ClaimKind = "string"
+	ClaimKindNumber ClaimKind = "number"
+	ClaimKindArray  ClaimKind = "array"
+	ClaimKindObject ClaimKind = "object"
+)
+
+// A Product is a read-only view of a single product of KopanoProductClaims.
+type Product struct {
+	name string
+	p    *api.ClaimsKopanoProductsResponseProduct
+}
+
+// ProductNames returns the sorted names of all products of the associated
+// claims, including products which are not licensed.
+func (kpc *KopanoProductClaims) ProductNames() []string {
+	names := make([]string, 0, len(kpc.response.Products))
+	for name := range kpc.response.Products {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Product returns a read-only view of the product with the provided name. The
+// same online and trusted rules as for the ensure functions apply.
+func (kpc *KopanoProductClaims) Product(name string) (*Product, error) {
+	p, err := kpc.getProduct(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Product{
+		name: name,
+		p:    p,
+	}, nil
+}
+
+// Name returns the name of the associated product.
+func (p *Product) Name() string {
+	return p.name
+}
+
+// OK returns true if the associated product is licensed.
+func (p *Product) OK() bool {
+	return p.p.OK
+}
+
+// ClaimNames returns the sorted names of all claims of the associated product.
+func (p *Product) ClaimNames() []string {
+	names := make([]string, 0, len(p.p.Claims))
+	for name := range p.p.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ClaimKind returns the kind of the claim value with the provided name of the
+// associated product, or ClaimKindNone if the product has no such claim. Like
+// with the getters, the name can be a JSON Pointer path to a nested value.
+func (p *Product) ClaimKind(name string) ClaimKind {
+	if isClaimPath(name) {
+		v, err := resolveClaimPath(p.p.Claims, p.name, name)
+		if err != nil {
+			return ClaimKindNone
+		}
+		return claimValueKind(v)
+	}
+	v, ok := p.p.Claims[name]
+	if !ok {
+		return ClaimKindNone
+	}
+	return claimValueKind(v)
+}
+
+func claimValueKind(v interface{}) ClaimKind {
+	switch v.(type) {
+	case nil:
+		return ClaimKindNull
+	case bool:
+		return ClaimKindBool
+	case string:
+		return ClaimKindString
+	case float64, json.Number:
+		return ClaimKindNumber
+	case []interface{}:
+		return ClaimKindArray
+	case map[string]interface{}:
+		return ClaimKindObject
+	}
+	return ClaimKindNone
+}
@@ -0,0 +1,46 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProductView(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+
+	if names := kpc.ProductNames(); !reflect.DeepEqual(names, []string{"groupware", "meet"}) {
+		t.Errorf("unexpected product names: %v", names)
+	}
+
+	p, err := kpc.Product("groupware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.OK() || p.Name() != "groupware" {
+		t.Errorf("unexpected product: %v %v", p.Name(), p.OK())
+	}
+	if names := p.ClaimNames(); !reflect.DeepEqual(names, []string{"edition", "features", "hosted", "max_users"}) {
+		t.Errorf("unexpected claim names: %v", names)
+	}
+	for claim, expected := range map[string]ClaimKind{
+		"edition":   ClaimKindString,
+		"features":  ClaimKindArray,
+		"hosted":    ClaimKindBool,
+		"max_users": ClaimKindNumber,
+		"missing":   ClaimKindNone,
+	} {
+		if kind := p.ClaimKind(claim); kind != expected {
+			t.Errorf("%s: unexpected kind: %v", claim, kind)
+		}
+	}
+
+	if _, err = kpc.Product("unknown"); !errors.Is(err, ErrEnsureProductNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
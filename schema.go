@@ -0,0 +1,275 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A ClaimSchema describes the expected value of a single product claim. Kind
+// is the expected kind of the claim value. For ClaimKindArray, Items is the
+// kind of the array values, either ClaimKindString, the default, or
+// ClaimKindNumber. Integer requires number claims and number array values to
+// be an exact int64. For ClaimKindNumber and number arrays, Min and Max, when
+// set, are the inclusive range of allowed values.
+type ClaimSchema struct {
+	Name     string    `json:"name"`
+	Kind     ClaimKind `json:"kind"`
+	Items    ClaimKind `json:"items,omitempty"`
+	Required bool      `json:"required,omitempty"`
+
+	Integer bool     `json:"integer,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+}
+
+// A ProductSchema is the set of claim schemas of a single product.
+type ProductSchema struct {
+	Product string         `json:"product"`
+	Claims  []*ClaimSchema `json:"claims"`
+}
+
+// A SchemaViolation describes a product claim which does not match its
+// registered ClaimSchema. Err is the ErrNumeric an ensure function would
+// return for that claim.
+type SchemaViolation struct {
+	Product string
+	Claim   string
+	Err     ErrNumeric
+	Message string
+}
+
+func (v *SchemaViolation) Error() string {
+	return fmt.Sprintf("%s.%s: %s", v.Product, v.Claim, v.Message)
+}
+
+// Unwrap returns the ErrNumeric reason of the associated violation.
+func (v *SchemaViolation) Unwrap() error {
+	return v.Err
+}
+
+// ParseSchemas parses the provided JSON or YAML data as list of product
+// schemas. The data must be an object with a products list.
+func ParseSchemas(data []byte) ([]*ProductSchema, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("schema parse error: %w", err)
+	}
+	// Round trip through JSON, to only have a single set of field names.
+	b, err := json.Marshal(yamlToJSONValue(raw))
+	if err != nil {
+		return nil, fmt.Errorf("schema parse error: %w", err)
+	}
+	var file struct {
+		Products []*ProductSchema `json:"products"`
+	}
+	if err = json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("schema parse error: %w", err)
+	}
+
+	for i, s := range file.Products {
+		if s.Product == "" {
+			return nil, fmt.Errorf("schema product %d has no name", i)
+		}
+		for j, cs := range s.Claims {
+			if cs.Name == "" {
+				return nil, fmt.Errorf("schema product %s claim %d has no name", s.Product, j)
+			}
+			switch cs.Kind {
+			case ClaimKindNull, ClaimKindBool, ClaimKindString, ClaimKindNumber, ClaimKindArray, ClaimKindObject:
+			case ClaimKindNone:
+				return nil, fmt.Errorf("schema product %s claim %s has no kind", s.Product, cs.Name)
+			default:
+				return nil, fmt.Errorf("schema product %s claim %s has unknown kind: %s", s.Product, cs.Name, cs.Kind)
+			}
+			switch {
+			case cs.Items == ClaimKindNone:
+			case cs.Kind != ClaimKindArray:
+				return nil, fmt.Errorf("schema product %s claim %s has items but is no array", s.Product, cs.Name)
+			case cs.Items != ClaimKindString && cs.Items != ClaimKindNumber:
+				return nil, fmt.Errorf("schema product %s claim %s has unsupported items kind: %s", s.Product, cs.Name, cs.Items)
+			}
+		}
+	}
+	return file.Products, nil
+}
+
+// LoadSchemaFile reads and parses the schema file at the provided path.
+func LoadSchemaFile(path string) ([]*ProductSchema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := ParseSchemas(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return schemas, nil
+}
+
+// Validate validates the claims of the associated product in the provided
+// claims and returns all violations. Products which are not found or which are
+// not licensed have no claims to validate. The online and trusted state of the
+// provided claims is ignored.
+func (s *ProductSchema) Validate(kpc *KopanoProductClaims) []*SchemaViolation {
+	p, ok := kpc.response.Products[s.Product]
+	if !ok || !p.OK {
+		return nil
+	}
+
+	var violations []*SchemaViolation
+	for _, cs := range s.Claims {
+		violation := func(err ErrNumeric, format string, a ...interface{}) {
+			violations = append(violations, &SchemaViolation{
+				Product: s.Product,
+				Claim:   cs.Name,
+				Err:     err,
+				Message: fmt.Sprintf(format, a...),
+			})
+		}
+		// inRange reports a violation and returns false if the provided
+		// number is outside of the range of the claim schema.
+		inRange := func(v interface{}, at string) bool {
+			f, _ := claimNumber(v)
+			if cs.Min != nil && f < *cs.Min {
+				violation(ErrEnsureProductClaimValueMismatch, "value %v%s is below minimum %v", v, at, *cs.Min)
+				return false
+			}
+			if cs.Max != nil && f > *cs.Max {
+				violation(ErrEnsureProductClaimValueMismatch, "value %v%s is above maximum %v", v, at, *cs.Max)
+				return false
+			}
+			return true
+		}
+
+		v, found := p.Claims[cs.Name]
+		if !found {
+			if cs.Required {
+				violation(ErrEnsureProductClaimNotFound, "required claim is missing")
+			}
+			continue
+		}
+
+		kind := (&Product{p: p}).ClaimKind(cs.Name)
+		if kind != cs.Kind {
+			violation(ErrEnsureProductClaimValueTypeMismatch, "expected %s, got %s", cs.Kind, kind)
+			continue
+		}
+		if kind == ClaimKindArray {
+			items := cs.Items
+			if items == ClaimKindNone {
+				items = ClaimKindString
+			}
+			for i, iv := range v.([]interface{}) {
+				if itemKind := claimValueKind(iv); itemKind != items {
+					violation(ErrEnsureProductClaimValueTypeMismatch, "expected %s at index %d, got %s", items, i, itemKind)
+					break
+				}
+				if items != ClaimKindNumber {
+					continue
+				}
+				if _, exact := claimInt64(iv); cs.Integer && !exact {
+					violation(ErrEnsureProductClaimValueNotInteger, "expected integer at index %d, got %v", i, iv)
+					break
+				}
+				if !inRange(iv, fmt.Sprintf(" at index %d", i)) {
+					break
+				}
+			}
+			continue
+		}
+		if kind != ClaimKindNumber {
+			continue
+		}
+
+		if cs.Integer {
+			if _, exact := claimInt64(v); !exact {
+				violation(ErrEnsureProductClaimValueNotInteger, "expected integer, got %v", v)
+				continue
+			}
+		}
+		inRange(v, "")
+	}
+	return violations
+}
+
+// RegisterSchema registers the provided product schema with the associated
+// instance. An already registered schema for the same product is replaced.
+// All registered schemas are validated whenever claims have been fetched.
+func (k *Kustomer) RegisterSchema(schema *ProductSchema) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.schemas == nil {
+		k.schemas = make(map[string]*ProductSchema)
+	}
+	k.schemas[schema.Product] = schema
+}
+
+// SetSchemaViolationHandler sets the provided function to be called with the
+// violations found when validating fetched claims. The handler is only called
+// if there are violations. Pass nil to remove the handler.
+func (k *Kustomer) SetSchemaViolationHandler(handler func([]*SchemaViolation)) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.schemaViolationHandler = handler
+}
+
+// Validate validates the active claims of the associated instance with all
+// registered schemas and returns all violations.
+func (k *Kustomer) Validate(ctx context.Context) []*SchemaViolation {
+	return k.validateSchemas(k.CurrentKopanoProductClaims(ctx))
+}
+
+func (k *Kustomer) validateSchemas(kpc *KopanoProductClaims) []*SchemaViolation {
+	k.mutex.RLock()
+	products := make([]string, 0, len(k.schemas))
+	for product := range k.schemas {
+		products = append(products, product)
+	}
+	sort.Strings(products)
+	schemas := make([]*ProductSchema, len(products))
+	for i, product := range products {
+		schemas[i] = k.schemas[product]
+	}
+	k.mutex.RUnlock()
+
+	var violations []*SchemaViolation
+	for _, schema := range schemas {
+		violations = append(violations, schema.Validate(kpc)...)
+	}
+	return violations
+}
+
+func (k *Kustomer) reportSchemaViolations(kpc *KopanoProductClaims) {
+	violations := k.validateSchemas(kpc)
+	if len(violations) == 0 {
+		return
+	}
+
+	k.mutex.RLock()
+	handler := k.schemaViolationHandler
+	debug := k.debug
+	logger := k.logger
+	k.mutex.RUnlock()
+
+	if debug {
+		for _, violation := range violations {
+			logger.Printf("libkustomer claim schema violation: %v\n", violation)
+		}
+	}
+	if handler != nil {
+		handler(violations)
+	}
+}
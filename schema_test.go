@@ -0,0 +1,56 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProductSchemaValidate(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Products["groupware"].Claims["ratio"] = 1.5
+	kpc.response.Products["groupware"].Claims["tiers"] = []interface{}{float64(10), 10.5}
+	kpc.response.Products["groupware"].Claims["quotas"] = []interface{}{float64(5), float64(100)}
+
+	minUsers, maxUsers := float64(1), float64(50)
+	schema := &ProductSchema{
+		Product: "groupware",
+		Claims: []*ClaimSchema{
+			{Name: "edition", Kind: ClaimKindString, Required: true},
+			{Name: "features", Kind: ClaimKindArray},
+			{Name: "max_users", Kind: ClaimKindNumber, Integer: true, Min: &minUsers, Max: &maxUsers},
+			{Name: "hosted", Kind: ClaimKindString},
+			{Name: "ratio", Kind: ClaimKindNumber, Integer: true},
+			{Name: "region", Kind: ClaimKindString, Required: true},
+			{Name: "optional", Kind: ClaimKindBool},
+			{Name: "tiers", Kind: ClaimKindArray, Items: ClaimKindNumber, Integer: true},
+			{Name: "quotas", Kind: ClaimKindArray, Items: ClaimKindNumber, Min: &minUsers, Max: &maxUsers},
+		},
+	}
+
+	expected := map[string]error{
+		"max_users": ErrEnsureProductClaimValueMismatch,
+		"hosted":    ErrEnsureProductClaimValueTypeMismatch,
+		"ratio":     ErrEnsureProductClaimValueNotInteger,
+		"region":    ErrEnsureProductClaimNotFound,
+		"tiers":     ErrEnsureProductClaimValueNotInteger,
+		"quotas":    ErrEnsureProductClaimValueMismatch,
+	}
+	violations := schema.Validate(kpc)
+	if len(violations) != len(expected) {
+		t.Fatalf("unexpected violations: %v", violations)
+	}
+	for _, violation := range violations {
+		if !errors.Is(violation, expected[violation.Claim]) {
+			t.Errorf("unexpected violation: %v", violation)
+		}
+	}
+
+	if violations = (&ProductSchema{Product: "meet", Claims: schema.Claims}).Validate(kpc); len(violations) != 0 {
+		t.Errorf("unexpected violations for unlicensed product: %v", violations)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	api "stash.kopano.io/kgol/kustomer/server/api-v1"
+)
+
+// snapshot is the serialized form of KopanoProductClaims. MAC is the
+// HMAC-SHA256 of Created and Payload, which includes the trusted flag.
+type snapshot struct {
+	Created time.Time       `json:"created"`
+	Payload json.RawMessage `json:"payload"`
+	MAC     []byte          `json:"mac,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The resulting snapshot
+// is not signed, use MarshalSignedJSON to keep the trusted state across
+// processes.
+func (kpc *KopanoProductClaims) MarshalJSON() ([]byte, error) {
+	return kpc.MarshalSignedJSON(nil)
+}
+
+// MarshalSignedJSON returns the associated claims as JSON snapshot, signed
+// with the provided HMAC key. If key is empty, the snapshot is not signed.
+// The mustBeOnline and allowUntrusted flags are not part of the snapshot.
+func (kpc *KopanoProductClaims) MarshalSignedJSON(key []byte) ([]byte, error) {
+	payload, err := json.Marshal(kpc.response)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{
+		Created: kpc.now().UTC(),
+		Payload: payload,
+	}
+	if len(key) > 0 {
+		s.MAC = snapshotMAC(key, s.Created, payload)
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. As unsigned
+// snapshots can be forged, the restored claims are never trusted. Use
+// UnmarshalSignedJSON to restore the trusted state.
+func (kpc *KopanoProductClaims) UnmarshalJSON(data []byte) error {
+	restored, err := UnmarshalSignedJSON(data, nil)
+	if err != nil {
+		return err
+	}
+	*kpc = *restored
+	return nil
+}
+
+// UnmarshalSignedJSON restores KopanoProductClaims from the provided JSON
+// snapshot. Invalid snapshots fail with ErrEnsureInvalidSnapshot. If the
+// provided HMAC key is not empty, the snapshot must be signed with that key.
+// Without key, the restored claims are never trusted. Restored claims are
+// always offline, as they were not just validated by the Kustomer service.
+func UnmarshalSignedJSON(data []byte, key []byte) (*KopanoProductClaims, error) {
+	return UnmarshalSignedJSONWithMaxAge(data, key, 0)
+}
+
+// UnmarshalSignedJSONWithMaxAge is like UnmarshalSignedJSON, but furthermore
+// fails with ErrEnsureInvalidSnapshot if the snapshot was created longer than
+// maxAge ago. A maxAge of zero or less disables the check.
+func UnmarshalSignedJSONWithMaxAge(data []byte, key []byte, maxAge time.Duration) (*KopanoProductClaims, error) {
+	return UnmarshalSignedJSONWithClock(data, key, maxAge, SystemClock)
+}
+
+// UnmarshalSignedJSONWithClock is like UnmarshalSignedJSONWithMaxAge, but
+// uses the provided clock for the age check and for the time checks of the
+// restored claims.
+func UnmarshalSignedJSONWithClock(data []byte, key []byte, maxAge time.Duration, clock Clock) (*KopanoProductClaims, error) {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEnsureInvalidSnapshot, err)
+	}
+	if len(key) > 0 && !hmac.Equal(s.MAC, snapshotMAC(key, s.Created, s.Payload)) {
+		return nil, ErrEnsureInvalidSnapshot
+	}
+	if age := clock.Now().Sub(s.Created); maxAge > 0 && age > maxAge {
+		return nil, fmt.Errorf("%w: created %v ago", ErrEnsureInvalidSnapshot, age.Truncate(time.Second))
+	}
+
+	response := &api.ClaimsKopanoProductsResponse{}
+	decoder := json.NewDecoder(bytes.NewReader(s.Payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(response); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEnsureInvalidSnapshot, err)
+	}
+	if len(key) == 0 {
+		response.Trusted = false
+	}
+	response.Offline = true
+
+	return &KopanoProductClaims{
+		response: response,
+		clock:    clock,
+	}, nil
+}
+
+func snapshotMAC(key []byte, created time.Time, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(created.UTC().Format(time.RFC3339Nano))) //nolint:errcheck
+	mac.Write([]byte{0})                                      //nolint:errcheck
+	mac.Write(payload)                                        //nolint:errcheck
+	return mac.Sum(nil)
+}
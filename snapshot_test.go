@@ -0,0 +1,106 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	key := []byte("secret")
+
+	signed, err := kpc.MarshalSignedJSON(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalSignedJSON(signed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = restored.EnsureTrusted(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err = restored.EnsureInt64("groupware", "max_users", 100); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err = UnmarshalSignedJSON(signed, []byte("other")); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	forged := bytes.Replace(signed, []byte(`"max_users":100`), []byte(`"max_users":1000`), 1)
+	if _, err = UnmarshalSignedJSON(forged, key); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Unsigned snapshots are never trusted.
+	b, err := json.Marshal(kpc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := &KopanoProductClaims{}
+	if err = json.Unmarshal(b, unsigned); err != nil {
+		t.Fatal(err)
+	}
+	if err = unsigned.EnsureTrusted(); !errors.Is(err, ErrEnsureTrustedFailed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = UnmarshalSignedJSON(b, key); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSnapshotMaxAge(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.clock = NewFakeClock(time.Now().Add(-2 * time.Hour))
+	key := []byte("secret")
+
+	signed, err := kpc.MarshalSignedJSON(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = UnmarshalSignedJSONWithMaxAge(signed, key, time.Hour); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("expected snapshot to be too old, got %v", err)
+	}
+	restored, err := UnmarshalSignedJSONWithMaxAge(signed, key, 3*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = restored.EnsureTrusted(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err = restored.EnsureOnline(); !errors.Is(err, ErrEnsureOnlineFailed) {
+		t.Errorf("expected restored claims to be offline, got %v", err)
+	}
+
+	// The age is checked with the provided clock.
+	clock := NewFakeClock(time.Now())
+	if _, err = UnmarshalSignedJSONWithClock(signed, key, 3*time.Hour, clock); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err = UnmarshalSignedJSONWithClock(signed, key, 3*time.Hour, clock); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("expected snapshot to be too old with advanced clock, got %v", err)
+	}
+
+	// The creation time is signed.
+	var s map[string]interface{}
+	if err = json.Unmarshal(signed, &s); err != nil {
+		t.Fatal(err)
+	}
+	s["created"] = time.Now().UTC().Format(time.RFC3339Nano)
+	forged, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = UnmarshalSignedJSONWithMaxAge(forged, key, time.Hour); !errors.Is(err, ErrEnsureInvalidSnapshot) {
+		t.Errorf("expected forged creation time to fail, got %v", err)
+	}
+}
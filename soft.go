@@ -0,0 +1,153 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"time"
+)
+
+// A SoftFailure describes a failed ensure check which was not enforced because
+// its product is in soft enforcement mode.
+type SoftFailure struct {
+	Time      time.Time
+	Operation string
+	Product   string
+	Claim     string
+	Err       error
+}
+
+func (f *SoftFailure) Error() string {
+	return "soft enforced: " + f.Err.Error()
+}
+
+// Unwrap returns the error the associated check would have returned.
+func (f *SoftFailure) Unwrap() error {
+	return f.Err
+}
+
+// softEnforcement is the soft enforcement setting of a Kustomer instance. It is
+// replaced, never modified, when the setting changes, so claims can keep a
+// reference to it.
+type softEnforcement struct {
+	products map[string]time.Time
+	logger   Logger
+	handler  func(*SoftFailure)
+}
+
+// active returns true if the provided product is in soft enforcement mode at
+// the provided time.
+func (s *softEnforcement) active(product string, now time.Time) bool {
+	if s == nil {
+		return false
+	}
+	until, ok := s.products[product]
+	return ok && (until.IsZero() || now.Before(until))
+}
+
+// SetSoftEnforcement puts the provided product in soft enforcement mode until
+// the provided time, or without end if until is the zero time. In that mode,
+// Ensure* checks of the product which fail with a denial (see
+// ErrNumeric.Denial) are reported to the logger and the soft failure handler,
+// but return success. Usage errors, like ErrEnsureUnknownOperator, and
+// infrastructure failures, like ErrEnsureTrustedFailed, are always returned.
+// EnsureTrusted and all checks which are not bound to a single product, like
+// EnsureExpr, are never affected. Only claims returned by
+// CurrentKopanoProductClaims after the change are affected.
+func (k *Kustomer) SetSoftEnforcement(product string, until time.Time) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.updateSoftEnforcement(func(products map[string]time.Time) {
+		products[product] = until
+	})
+}
+
+// ClearSoftEnforcement removes the provided product from soft enforcement mode.
+func (k *Kustomer) ClearSoftEnforcement(product string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.updateSoftEnforcement(func(products map[string]time.Time) {
+		delete(products, product)
+	})
+}
+
+// SetSoftFailureHandler sets the provided function to be called with every
+// failed check which was not enforced. Pass nil to remove the handler.
+func (k *Kustomer) SetSoftFailureHandler(handler func(*SoftFailure)) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.softFailureHandler = handler
+	k.updateSoftEnforcement(func(map[string]time.Time) {})
+}
+
+// updateSoftEnforcement replaces the soft enforcement setting of the associated
+// instance. It must be called with the instance mutex locked.
+func (k *Kustomer) updateSoftEnforcement(fn func(map[string]time.Time)) {
+	products := make(map[string]time.Time)
+	if k.softEnforcement != nil {
+		for product, until := range k.softEnforcement.products {
+			products[product] = until
+		}
+	}
+	fn(products)
+
+	if len(products) == 0 {
+		k.softEnforcement = nil
+		return
+	}
+	k.softEnforcement = &softEnforcement{
+		products: products,
+		logger:   k.logger,
+		handler:  k.softFailureHandler,
+	}
+}
+
+// WithoutSoftEnforcement returns a view of the associated claims with soft
+// enforcement disabled, so checks return the errors they would have returned
+// without it. The associated claims are not modified.
+func (kpc *KopanoProductClaims) WithoutSoftEnforcement() *KopanoProductClaims {
+	derived := *kpc
+	derived.softEnforcement = nil
+	return &derived
+}
+
+// ensured applies soft enforcement to the decision of the provided ensure
+// operation and records it like audit. It is meant to be deferred with a
+// pointer to the named error result of the operation.
+func (kpc *KopanoProductClaims) ensured(operation, product, claim string, errp *error) {
+	err := *errp
+	now := kpc.now()
+	soft := err != nil && asErrNumeric(err).Denial() && kpc.softEnforcement.active(product, now)
+
+	if kpc.auditHook != nil {
+		kpc.record(&AuditRecord{
+			Operation: operation,
+			Product:   product,
+			Claim:     claim,
+			Soft:      soft,
+		}, err)
+	}
+	if !soft {
+		return
+	}
+
+	failure := &SoftFailure{
+		Time:      now,
+		Operation: operation,
+		Product:   product,
+		Claim:     claim,
+		Err:       err,
+	}
+	if kpc.softEnforcement.logger != nil {
+		kpc.softEnforcement.logger.Printf("libkustomer %s %v\n", operation, failure)
+	}
+	if kpc.softEnforcement.handler != nil {
+		kpc.softEnforcement.handler(failure)
+	}
+	*errp = nil
+}
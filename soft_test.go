@@ -0,0 +1,90 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSoftEnforcement(t *testing.T) {
+	k, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failures []*SoftFailure
+	k.SetSoftFailureHandler(func(failure *SoftFailure) {
+		failures = append(failures, failure)
+	})
+	k.SetSoftEnforcement("groupware", time.Time{})
+	k.SetSoftEnforcement("meet", time.Now().Add(-time.Hour))
+
+	kpc := newTestKopanoProductClaims()
+	kpc.softEnforcement = k.softEnforcement
+
+	if err = kpc.EnsureString("groupware", "edition", "basic"); err != nil {
+		t.Errorf("expected soft enforced success, got %v", err)
+	}
+	if err = kpc.EnsureInt64InRange("groupware", "max_users", 200, 300, true); err != nil {
+		t.Errorf("expected soft enforced success, got %v", err)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 soft failures, got %d", len(failures))
+	}
+	if failures[0].Claim != "edition" || !errors.Is(failures[0], ErrEnsureProductClaimValueMismatch) {
+		t.Errorf("unexpected soft failure: %v", failures[0])
+	}
+
+	if err = kpc.WithoutSoftEnforcement().EnsureString("groupware", "edition", "basic"); !errors.Is(err, ErrEnsureProductClaimValueMismatch) {
+		t.Errorf("expected strict mismatch, got %v", err)
+	}
+	if err = kpc.EnsureOK("meet"); !errors.Is(err, ErrEnsureProductNotLicensed) {
+		t.Errorf("expected expired soft enforcement to be enforced, got %v", err)
+	}
+	if err = kpc.EnsureExpr(`groupware.edition == "basic"`); err == nil {
+		t.Errorf("expected expression to be enforced")
+	}
+
+	untrusted := newTestKopanoProductClaims()
+	untrusted.response.Trusted = false
+	untrusted.softEnforcement = k.softEnforcement
+	if err = untrusted.EnsureString("groupware", "edition", "enterprise"); !errors.Is(err, ErrEnsureTrustedFailed) {
+		t.Errorf("expected trust to be enforced, got %v", err)
+	}
+	if err = untrusted.EnsureTrusted(); !errors.Is(err, ErrEnsureTrustedFailed) {
+		t.Errorf("expected trust to be enforced, got %v", err)
+	}
+	if err = kpc.EnsureStringWithOperator("groupware", "edition", "basic", OperatorGreaterThan); !errors.Is(err, ErrEnsureUnknownOperator) {
+		t.Errorf("expected usage error to be returned, got %v", err)
+	}
+	if err = kpc.EnsureStringWithOperator("groupware", "edition", "(", OperatorRegexp); !errors.Is(err, ErrEnsureInvalidPattern) {
+		t.Errorf("expected usage error to be returned, got %v", err)
+	}
+	if len(failures) != 2 {
+		t.Errorf("expected no further soft failures, got %d", len(failures))
+	}
+
+	var records []*AuditRecord
+	audited := kpc.WithAuditHook(func(record *AuditRecord) {
+		records = append(records, record)
+	})
+	if err = audited.EnsureString("groupware", "edition", "basic"); err != nil {
+		t.Errorf("expected soft enforced success, got %v", err)
+	}
+	if err = audited.EnsureString("groupware", "edition", "enterprise"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || !records[0].Soft || records[0].Result != ErrEnsureProductClaimValueMismatch || records[1].Soft {
+		t.Errorf("expected soft enforced failure to be audited as such, got %+v", records)
+	}
+
+	k.ClearSoftEnforcement("groupware")
+	k.ClearSoftEnforcement("meet")
+	if k.softEnforcement != nil {
+		t.Errorf("expected soft enforcement to be cleared")
+	}
+}
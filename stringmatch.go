@@ -0,0 +1,118 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"container/list"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexpCacheSize is the maximum number of compiled regular expressions kept
+// in regexpCache.
+const regexpCacheSize = 128
+
+// regexpCache caches compiled regular expressions by pattern, as the same few
+// patterns are typically used over and over again by ensure checks. Patterns
+// can come from callers, so the least recently used pattern is dropped once the
+// cache is full.
+var regexpCache = &lruRegexpCache{
+	entries: make(map[string]*list.Element),
+	order:   list.New(),
+}
+
+type lruRegexpCache struct {
+	mutex sync.Mutex
+
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruRegexpCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (c *lruRegexpCache) get(pattern string) (*regexp.Regexp, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[pattern]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruRegexpCacheEntry).re, true
+}
+
+func (c *lruRegexpCache) add(pattern string, re *regexp.Regexp) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[pattern] = c.order.PushFront(&lruRegexpCacheEntry{pattern, re})
+	for c.order.Len() > regexpCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruRegexpCacheEntry).pattern)
+	}
+}
+
+func (c *lruRegexpCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+func compileAnchoredRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.get(pattern); ok {
+		return re, nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.add(pattern, re)
+	return re, nil
+}
+
+// matchString returns true if the provided value matches the provided pattern
+// using the provided string operator. The returned error is
+// ErrEnsureUnknownOperator for unsupported operators and
+// ErrEnsureInvalidPattern if the pattern cannot be used with the operator.
+func matchString(op OperatorType, value, pattern string) (bool, error) {
+	switch op {
+	case OperatorEqual:
+		return value == pattern, nil
+	case OperatorNotEqual:
+		return value != pattern, nil
+	case OperatorEqualFold:
+		return strings.EqualFold(value, pattern), nil
+	case OperatorHasPrefix:
+		return strings.HasPrefix(value, pattern), nil
+	case OperatorHasSuffix:
+		return strings.HasSuffix(value, pattern), nil
+	case OperatorGlob:
+		matched, err := path.Match(pattern, value)
+		if err != nil {
+			return false, ErrEnsureInvalidPattern
+		}
+		return matched, nil
+	case OperatorRegexp:
+		re, err := compileAnchoredRegexp(pattern)
+		if err != nil {
+			return false, ErrEnsureInvalidPattern
+		}
+		return re.MatchString(value), nil
+	}
+	return false, ErrEnsureUnknownOperator
+}
@@ -0,0 +1,63 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-or-later
+ * Copyright 2021 Kopano and its licensors
+ */
+
+package kustomer
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestEnsureStringWithOperator(t *testing.T) {
+	kpc := newTestKopanoProductClaims()
+	kpc.response.Products["groupware"].Claims["domain"] = "mail.example.com"
+
+	for _, tc := range []struct {
+		op       OperatorType
+		value    string
+		expected error
+	}{
+		{OperatorEqual, "mail.example.com", nil},
+		{OperatorEqual, "MAIL.Example.com", ErrEnsureProductClaimValueMismatch},
+		{OperatorNotEqual, "other.example.com", nil},
+		{OperatorNotEqual, "mail.example.com", ErrEnsureProductClaimValueMismatch},
+		{OperatorEqualFold, "MAIL.Example.com", nil},
+		{OperatorHasPrefix, "mail.", nil},
+		{OperatorHasSuffix, ".example.com", nil},
+		{OperatorHasSuffix, ".example.org", ErrEnsureProductClaimValueMismatch},
+		{OperatorGlob, "*.example.com", nil},
+		{OperatorGlob, "[", ErrEnsureInvalidPattern},
+		{OperatorRegexp, `[a-z]+\.example\.com`, nil},
+		{OperatorRegexp, `example\.com`, ErrEnsureProductClaimValueMismatch},
+		{OperatorRegexp, `(`, ErrEnsureInvalidPattern},
+		{OperatorGreaterThan, "mail", ErrEnsureUnknownOperator},
+	} {
+		if err := kpc.EnsureStringWithOperator("groupware", "domain", tc.value, tc.op); !errors.Is(err, tc.expected) {
+			t.Errorf("%s %s: unexpected error: %v", tc.op, tc.value, err)
+		}
+	}
+}
+
+func TestRegexpCacheBounded(t *testing.T) {
+	first, err := compileAnchoredRegexp("first-[0-9]+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*regexpCacheSize; i++ {
+		if _, err = compileAnchoredRegexp("pattern-" + strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := regexpCache.len(); n != regexpCacheSize {
+		t.Errorf("expected %d cached patterns, got %d", regexpCacheSize, n)
+	}
+	if _, ok := regexpCache.get("first-[0-9]+"); ok {
+		t.Errorf("expected least recently used pattern to be dropped")
+	}
+	if again, _ := compileAnchoredRegexp("first-[0-9]+"); again == first || !again.MatchString("first-1") {
+		t.Errorf("expected dropped pattern to be compiled again")
+	}
+}